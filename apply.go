@@ -0,0 +1,494 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/vrischmann/happyreaper/errors"
+	"github.com/vrischmann/happyreaper/reaper"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// scheduleSpec is the desired state of a repair schedule.
+type scheduleSpec struct {
+	Cluster     string             `json:"cluster" yaml:"cluster"`
+	Keyspace    string             `json:"keyspace" yaml:"keyspace"`
+	Tables      []string           `json:"tables,omitempty" yaml:"tables,omitempty"`
+	Owner       string             `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Intensity   float64            `json:"intensity,omitempty" yaml:"intensity,omitempty"`
+	Incremental bool               `json:"incremental,omitempty" yaml:"incremental,omitempty"`
+	Parallelism reaper.Parallelism `json:"parallelism,omitempty" yaml:"parallelism,omitempty"`
+	Segments    int                `json:"segments,omitempty" yaml:"segments,omitempty"`
+	// SegmentsPerNode replaces Segments with newer Reaper versions.
+	SegmentsPerNode int `json:"segments_per_node,omitempty" yaml:"segments_per_node,omitempty"`
+	DaysBetween     int `json:"days_between,omitempty" yaml:"days_between,omitempty"`
+	// TriggerTime is only used when the schedule is created.
+	TriggerTime string `json:"trigger_time,omitempty" yaml:"trigger_time,omitempty"`
+}
+
+// scheduleSpecFile is the file read by apply. YAML being a superset of JSON, it can be written in both.
+type scheduleSpecFile struct {
+	Schedules []scheduleSpec `json:"schedules" yaml:"schedules"`
+}
+
+// scheduleKey identifies a repair schedule, Reaper allows only one schedule per cluster, keyspace and tables.
+func scheduleKey(cluster, keyspace string, tables []string) string {
+	return keyspaceKey(cluster, keyspace) + tablesSuffix(tables)
+}
+
+// segmentCount returns the number of segments reported by Reaper, the total or the number per node depending on its version.
+// It is zero if unknown.
+func segmentCount(sched reaper.RepairSchedule) int {
+	if sched.SegmentCountPerNode > 0 {
+		return sched.SegmentCountPerNode
+	}
+	return sched.SegmentCount
+}
+
+// tablesSuffix formats the tables of a schedule independently of their order, it is empty for all the tables.
+func tablesSuffix(tables []string) string {
+	if len(tables) == 0 {
+		return ""
+	}
+
+	sorted := append([]string(nil), tables...)
+	sort.Strings(sorted)
+
+	return " [" + strings.Join(sorted, ",") + "]"
+}
+
+func (s scheduleSpec) key() string { return scheduleKey(s.Cluster, s.Keyspace, s.Tables) }
+
+// schedule returns the repair schedule described by s, for comparison with the existing ones.
+func (s scheduleSpec) schedule() reaper.RepairSchedule {
+	return reaper.RepairSchedule{
+		Owner:                s.Owner,
+		ClusterName:          s.Cluster,
+		KeyspaceName:         s.Keyspace,
+		ColumnFamilies:       s.Tables,
+		Intensity:            s.Intensity,
+		IncrementalRepair:    s.Incremental,
+		RepairParallelism:    s.Parallelism,
+		ScheduledDaysBetween: s.DaysBetween,
+		SegmentCount:         s.Segments,
+		SegmentCountPerNode:  s.SegmentsPerNode,
+	}
+}
+
+func (s scheduleSpec) addParams() reaper.AddScheduleParams {
+	return reaper.AddScheduleParams{
+		Cluster:             s.Cluster,
+		Keyspace:            s.Keyspace,
+		Tables:              s.Tables,
+		Owner:               s.Owner,
+		Segments:            s.Segments,
+		SegmentsPerNode:     s.SegmentsPerNode,
+		Parallelism:         s.Parallelism,
+		Intensity:           s.Intensity,
+		Incremental:         s.Incremental,
+		ScheduleDaysBetween: s.DaysBetween,
+		ScheduleTriggerTime: s.TriggerTime,
+	}
+}
+
+// normalize fills the missing parameters with the same defaults as add-schedule and validates the others.
+func (s *scheduleSpec) normalize() error {
+	if s.Owner == "" {
+		s.Owner = defaults.Owner
+	}
+	if s.Intensity == 0 {
+		s.Intensity = defaults.Intensity
+	}
+	if s.Segments == 0 && s.SegmentsPerNode == 0 {
+		s.Segments = defaults.Segments
+	}
+	if s.DaysBetween == 0 {
+		s.DaysBetween = 14
+	}
+	if s.Parallelism == "" {
+		s.Parallelism = reaper.Sequential
+	}
+
+	switch {
+	case s.Cluster == "":
+		return errors.Str("the cluster is missing")
+	case s.Keyspace == "":
+		return errors.Str("the keyspace is missing")
+	case s.Owner == "":
+		return errors.Str("the owner is missing")
+	case s.Intensity < 0 || s.Intensity > 1:
+		return errors.Str("the intensity must be between 0 and 1")
+	case s.Segments < 0 || s.SegmentsPerNode < 0:
+		return errors.Str("the number of segments must be positive")
+	case s.Segments > 0 && s.SegmentsPerNode > 0:
+		return errors.Str("only one of segments and segments_per_node can be given")
+	case s.DaysBetween < 0:
+		return errors.Str("the number of days between repairs must be positive")
+	}
+
+	return s.Parallelism.Set(s.Parallelism.String())
+}
+
+func readScheduleSpecs(path string) ([]scheduleSpec, error) {
+	const op = "readScheduleSpecs"
+
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, errors.E(errors.IO, op, err)
+	}
+
+	var file scheduleSpecFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, errors.E(errors.Invalid, op, err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range file.Schedules {
+		spec := &file.Schedules[i]
+		if err := spec.normalize(); err != nil {
+			return nil, errors.E(errors.Invalid, op, errors.Errorf("schedule #%d: %s", i+1, err))
+		}
+		if seen[spec.key()] {
+			return nil, errors.E(errors.Invalid, op, errors.Errorf("schedule %s is defined twice", spec.key()))
+		}
+		seen[spec.key()] = true
+	}
+
+	return file.Schedules, nil
+}
+
+// fieldChange is a field of a repair schedule which differs.
+type fieldChange struct {
+	Field  string `json:"field" yaml:"field"`
+	Before string `json:"before" yaml:"before"`
+	After  string `json:"after" yaml:"after"`
+}
+
+// scheduleChanges returns the fields among names which differ between before and after.
+func scheduleChanges(before, after reaper.RepairSchedule, names []string) []fieldChange {
+	var res []fieldChange
+	for _, name := range names {
+		f, ok := findScheduleField(name)
+		if !ok {
+			continue
+		}
+		if b, a := f.get(before), f.get(after); b != a {
+			res = append(res, fieldChange{Field: name, Before: b, After: a})
+		}
+	}
+	return res
+}
+
+var (
+	// specFields are the fields of a repair schedule set by a spec.
+	specFields = []string{"owner", "intensity", "incremental_repair", "repair_parallelism", "scheduled_days_between", "segment_count", "segment_count_per_node"}
+	// updatableFields are the fields of a repair schedule which Reaper can change.
+	updatableFields = []string{"owner", "intensity", "repair_parallelism", "scheduled_days_between"}
+	// recreateFields are the fields of a repair schedule which can only be changed with a new schedule.
+	recreateFields = []string{"incremental_repair", "segment_count", "segment_count_per_node"}
+)
+
+const (
+	actionCreate  = "create"
+	actionUpdate  = "update"
+	actionReplace = "replace"
+	actionDelete  = "delete"
+)
+
+// scheduleChange is a change needed to make the repair schedules match their spec.
+type scheduleChange struct {
+	Action  string        `json:"action" yaml:"action"`
+	Key     string        `json:"key" yaml:"key"`
+	ID      string        `json:"id,omitempty" yaml:"id,omitempty"`
+	Changes []fieldChange `json:"changes,omitempty" yaml:"changes,omitempty"`
+
+	spec    scheduleSpec
+	current reaper.RepairSchedule
+	// paused is true if the schedule must be paused once created.
+	paused bool
+}
+
+// normalizeSegments returns a and b with the numbers of segments which one of them doesn't give set to 0 in both,
+// so that only the known ones are compared: older Reaper versions only report the total and newer ones only the number per node.
+func normalizeSegments(a, b reaper.RepairSchedule) (reaper.RepairSchedule, reaper.RepairSchedule) {
+	if a.SegmentCount == 0 || b.SegmentCount == 0 {
+		a.SegmentCount, b.SegmentCount = 0, 0
+	}
+	if a.SegmentCountPerNode == 0 || b.SegmentCountPerNode == 0 {
+		a.SegmentCountPerNode, b.SegmentCountPerNode = 0, 0
+	}
+	return a, b
+}
+
+// planSchedules computes the changes turning the existing schedules into specs.
+// Only the clusters with a spec are managed, the deleted schedules are ignored.
+func planSchedules(specs []scheduleSpec, existing []reaper.RepairSchedule) []scheduleChange {
+	current := make(map[string]reaper.RepairSchedule)
+
+	var res []scheduleChange
+	for _, sched := range existing {
+		if sched.State == reaper.SDeleted {
+			continue
+		}
+
+		key := scheduleKey(sched.ClusterName, sched.KeyspaceName, sched.ColumnFamilies)
+		if _, ok := current[key]; ok {
+			// A duplicate, only one schedule per key can be kept.
+			res = append(res, scheduleChange{Action: actionDelete, Key: key, ID: sched.ID, current: sched})
+			continue
+		}
+		current[key] = sched
+	}
+
+	wanted := make(map[string]bool)
+	for _, spec := range specs {
+		key := spec.key()
+		wanted[key] = true
+
+		sched, ok := current[key]
+		if !ok {
+			res = append(res, scheduleChange{
+				Action:  actionCreate,
+				Key:     key,
+				Changes: scheduleChanges(reaper.RepairSchedule{}, spec.schedule(), specFields),
+				spec:    spec,
+			})
+			continue
+		}
+
+		compared, desired := normalizeSegments(sched, spec.schedule())
+		if changes := scheduleChanges(compared, desired, recreateFields); len(changes) > 0 {
+			res = append(res, scheduleChange{
+				Action:  actionReplace,
+				Key:     key,
+				ID:      sched.ID,
+				Changes: append(changes, scheduleChanges(sched, desired, updatableFields)...),
+				spec:    spec,
+				current: sched,
+				paused:  sched.State == reaper.SPaused,
+			})
+			continue
+		}
+		if changes := scheduleChanges(sched, desired, updatableFields); len(changes) > 0 {
+			res = append(res, scheduleChange{Action: actionUpdate, Key: key, ID: sched.ID, Changes: changes, spec: spec, current: sched})
+		}
+	}
+
+	for key, sched := range current {
+		if !wanted[key] {
+			res = append(res, scheduleChange{Action: actionDelete, Key: key, ID: sched.ID, current: sched})
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+
+	return res
+}
+
+// apply makes the change in Reaper.
+func (c scheduleChange) apply() error {
+	switch c.Action {
+	case actionCreate:
+		return createSchedule(c.spec, c.paused)
+
+	case actionUpdate:
+		params := reaper.UpdateScheduleParams{
+			Owner:               c.spec.Owner,
+			Parallelism:         c.spec.Parallelism,
+			Intensity:           c.spec.Intensity,
+			ScheduleDaysBetween: c.spec.DaysBetween,
+		}
+		_, err := client.UpdateSchedule(ctx, c.ID, params)
+		return err
+
+	case actionReplace:
+		// Reaper refuses two schedules with the same keyspace and tables, the old one must go first.
+		if err := removeSchedule(c.current); err != nil {
+			return err
+		}
+		if err := createSchedule(c.spec, c.paused); err != nil {
+			return lostScheduleError(c.current, c.spec, err)
+		}
+		return nil
+
+	case actionDelete:
+		return removeSchedule(c.current)
+
+	default:
+		return errors.Errorf("invalid action %q", c.Action)
+	}
+}
+
+// lostScheduleError describes a schedule deleted to be replaced but which could not be created again,
+// with everything needed to create it by hand.
+func lostScheduleError(old reaper.RepairSchedule, spec scheduleSpec, err error) error {
+	data, merr := yaml.Marshal(spec)
+	if merr != nil {
+		data = []byte(fmt.Sprintf("%+v\n", spec))
+	}
+
+	return errors.Errorf("schedule %s was deleted but could not be created again: %s\nits specification was:\n%s", old.ID, describeError(err), data)
+}
+
+// createSchedule creates the schedule described by spec, and pauses it if paused is true.
+func createSchedule(spec scheduleSpec, paused bool) error {
+	res, err := client.AddSchedule(ctx, spec.addParams())
+	if err != nil || !paused {
+		return err
+	}
+
+	_, err = client.ChangeScheduleState(ctx, res.ID, reaper.SPaused)
+	return err
+}
+
+// removeSchedule deletes sched, pausing it first if needed since Reaper refuses to delete an active schedule.
+func removeSchedule(sched reaper.RepairSchedule) error {
+	if sched.State == reaper.SActive {
+		if _, err := client.ChangeScheduleState(ctx, sched.ID, reaper.SPaused); err != nil {
+			return err
+		}
+	}
+
+	_, err := client.DeleteSchedule(ctx, sched.ID, sched.Owner)
+	return err
+}
+
+func printPlan(w io.Writer, changes []scheduleChange) {
+	counts := make(map[string]int)
+
+	for _, c := range changes {
+		counts[c.Action]++
+
+		var (
+			symbol string
+			col    *color.Color
+		)
+		switch c.Action {
+		case actionCreate:
+			symbol, col = "+", color.New(color.FgGreen)
+		case actionUpdate:
+			symbol, col = "~", color.New(color.FgYellow)
+		case actionReplace:
+			symbol, col = "-/+", color.New(color.FgMagenta)
+		case actionDelete:
+			symbol, col = "-", color.New(color.FgRed)
+		}
+
+		title := fmt.Sprintf("%s %s schedule %s", symbol, c.Action, c.Key)
+		if c.ID != "" {
+			title += " (" + c.ID + ")"
+		}
+		col.Fprintln(w, title)
+
+		for _, fc := range c.Changes {
+			if c.Action == actionCreate {
+				fmt.Fprintf(w, "    %-25s %s\n", fc.Field+":", fc.After)
+				continue
+			}
+			fmt.Fprintf(w, "    %-25s %s -> %s\n", fc.Field+":", fc.Before, fc.After)
+		}
+	}
+
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "No changes, the schedules match the specification.")
+		return
+	}
+
+	fmt.Fprintf(w, "\nPlan: %d to create, %d to update, %d to replace, %d to delete.\n",
+		counts[actionCreate], counts[actionUpdate], counts[actionReplace], counts[actionDelete])
+}
+
+func applySchedules(args []string) error {
+	var (
+		fs       = flag.NewFlagSet("apply", flag.ContinueOnError)
+		flFile   = fs.String("f", "", "The file describing the schedules, - reads the standard input")
+		flDryRun = fs.Bool("dry-run", false, "Only print the changes which would be made")
+		flYes    = fs.Bool("yes", false, "Don't ask for confirmation")
+	)
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if *flFile == "" {
+		return errors.Str("please provide a file")
+	}
+
+	specs, err := readScheduleSpecs(*flFile)
+	if err != nil {
+		return err
+	}
+
+	var clusters []string
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		if !seen[spec.Cluster] {
+			seen[spec.Cluster] = true
+			clusters = append(clusters, spec.Cluster)
+		}
+	}
+
+	var existing []reaper.RepairSchedule
+	for _, cluster := range clusters {
+		res, err := callListSchedules(cluster, "")
+		if err != nil {
+			return err
+		}
+		existing = append(existing, res...)
+	}
+
+	changes := planSchedules(specs, existing)
+
+	if flOutput != OutputText {
+		var w io.Writer = os.Stdout
+		if !*flDryRun {
+			w = previewWriter()
+		}
+		if err := writeOutput(w, flOutput, changes); err != nil {
+			return err
+		}
+	} else {
+		printPlan(os.Stdout, changes)
+	}
+
+	if *flDryRun || len(changes) == 0 {
+		return nil
+	}
+
+	if !*flYes {
+		ok, err := confirm("Apply these %d changes?", len(changes))
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	// The changes are made one at a time, in the order of the plan.
+	results := make([]bulkResult, 0, len(changes))
+	for _, c := range changes {
+		res := bulkResult{ID: c.Action + " " + c.Key}
+		if err := c.apply(); err != nil {
+			res.Error = describeError(err)
+		}
+		results = append(results, res)
+	}
+
+	return reportBulk(results)
+}
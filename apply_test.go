@@ -0,0 +1,197 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vrischmann/happyreaper/reaper"
+)
+
+func testSpec(keyspace string) scheduleSpec {
+	return scheduleSpec{
+		Cluster:     "c1",
+		Keyspace:    keyspace,
+		Owner:       "ops",
+		Intensity:   0.5,
+		Parallelism: reaper.Sequential,
+		Segments:    200,
+		DaysBetween: 14,
+	}
+}
+
+func testSchedule(id, keyspace string) reaper.RepairSchedule {
+	return reaper.RepairSchedule{
+		ID:                   id,
+		State:                reaper.SActive,
+		Owner:                "ops",
+		ClusterName:          "c1",
+		KeyspaceName:         keyspace,
+		Intensity:            0.5,
+		RepairParallelism:    reaper.Sequential,
+		ScheduledDaysBetween: 14,
+		SegmentCount:         200,
+	}
+}
+
+func TestPlanSchedules(t *testing.T) {
+	withIntensity := testSpec("ks1")
+	withIntensity.Intensity = 0.9
+
+	withSegments := testSpec("ks1")
+	withSegments.Segments = 400
+
+	withTables := testSpec("ks1")
+	withTables.Tables = []string{"t2", "t1"}
+
+	deleted := testSchedule("1", "ks1")
+	deleted.State = reaper.SDeleted
+
+	withIncremental := testSpec("ks1")
+	withIncremental.Incremental = true
+
+	withSegmentsPerNode := testSpec("ks1")
+	withSegmentsPerNode.Segments, withSegmentsPerNode.SegmentsPerNode = 0, 64
+
+	unknownSegments := testSchedule("1", "ks1")
+	unknownSegments.SegmentCount = 0
+
+	perNode := testSchedule("1", "ks1")
+	perNode.SegmentCount, perNode.SegmentCountPerNode = 0, 32
+
+	tablesInOtherOrder := testSchedule("1", "ks1")
+	tablesInOtherOrder.ColumnFamilies = []string{"t1", "t2"}
+
+	type action struct {
+		action string
+		key    string
+		id     string
+	}
+
+	testCases := []struct {
+		name     string
+		specs    []scheduleSpec
+		existing []reaper.RepairSchedule
+		exp      []action
+	}{
+		{
+			"nothing to do",
+			[]scheduleSpec{testSpec("ks1")},
+			[]reaper.RepairSchedule{testSchedule("1", "ks1")},
+			nil,
+		},
+		{
+			"create",
+			[]scheduleSpec{testSpec("ks1")},
+			nil,
+			[]action{{actionCreate, "c1/ks1", ""}},
+		},
+		{
+			"deleted schedules are ignored",
+			[]scheduleSpec{testSpec("ks1")},
+			[]reaper.RepairSchedule{deleted},
+			[]action{{actionCreate, "c1/ks1", ""}},
+		},
+		{
+			"update",
+			[]scheduleSpec{withIntensity},
+			[]reaper.RepairSchedule{testSchedule("1", "ks1")},
+			[]action{{actionUpdate, "c1/ks1", "1"}},
+		},
+		{
+			"replace to change the segments",
+			[]scheduleSpec{withSegments},
+			[]reaper.RepairSchedule{testSchedule("1", "ks1")},
+			[]action{{actionReplace, "c1/ks1", "1"}},
+		},
+		{
+			"replace to change the incremental repair",
+			[]scheduleSpec{withIncremental},
+			[]reaper.RepairSchedule{testSchedule("1", "ks1")},
+			[]action{{actionReplace, "c1/ks1", "1"}},
+		},
+		{
+			"replace to change the segments per node",
+			[]scheduleSpec{withSegmentsPerNode},
+			[]reaper.RepairSchedule{perNode},
+			[]action{{actionReplace, "c1/ks1", "1"}},
+		},
+		{
+			"segments per node not given",
+			[]scheduleSpec{testSpec("ks1")},
+			[]reaper.RepairSchedule{perNode},
+			nil,
+		},
+		{
+			"unknown segments are not compared",
+			[]scheduleSpec{withSegments},
+			[]reaper.RepairSchedule{unknownSegments},
+			nil,
+		},
+		{
+			"delete the unmanaged schedules",
+			[]scheduleSpec{testSpec("ks1")},
+			[]reaper.RepairSchedule{testSchedule("1", "ks1"), testSchedule("2", "ks2")},
+			[]action{{actionDelete, "c1/ks2", "2"}},
+		},
+		{
+			"delete the duplicates",
+			[]scheduleSpec{testSpec("ks1")},
+			[]reaper.RepairSchedule{testSchedule("1", "ks1"), testSchedule("2", "ks1")},
+			[]action{{actionDelete, "c1/ks1", "2"}},
+		},
+		{
+			"tables in any order",
+			[]scheduleSpec{withTables},
+			[]reaper.RepairSchedule{tablesInOtherOrder},
+			nil,
+		},
+		{
+			"tables are part of the key",
+			[]scheduleSpec{withTables},
+			[]reaper.RepairSchedule{testSchedule("1", "ks1")},
+			[]action{{actionDelete, "c1/ks1", "1"}, {actionCreate, "c1/ks1 [t1,t2]", ""}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var res []action
+			for _, c := range planSchedules(tc.specs, tc.existing) {
+				res = append(res, action{c.Action, c.Key, c.ID})
+			}
+			if !reflect.DeepEqual(res, tc.exp) {
+				t.Errorf("got %v, expected %v", res, tc.exp)
+			}
+		})
+	}
+}
+
+func TestPlanSchedulesKeepsPaused(t *testing.T) {
+	spec := testSpec("ks1")
+	spec.Segments = 400
+
+	sched := testSchedule("1", "ks1")
+	sched.State = reaper.SPaused
+
+	changes := planSchedules([]scheduleSpec{spec}, []reaper.RepairSchedule{sched})
+	if len(changes) != 1 || !changes[0].paused {
+		t.Errorf("got %+v, expected a replace of a paused schedule", changes)
+	}
+}
+
+func TestScheduleChanges(t *testing.T) {
+	before := testSchedule("1", "ks1")
+	after := testSchedule("2", "ks1")
+	after.Intensity = 0.9
+	after.Owner = "dev"
+
+	res := scheduleChanges(before, after, specFields)
+
+	exp := []fieldChange{
+		{Field: "owner", Before: "ops", After: "dev"},
+		{Field: "intensity", Before: "0.500", After: "0.900"},
+	}
+	if !reflect.DeepEqual(res, exp) {
+		t.Errorf("got %v, expected %v", res, exp)
+	}
+}
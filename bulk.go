@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/vrischmann/happyreaper/errors"
+	"github.com/vrischmann/happyreaper/reaper"
+)
+
+// bulkOptions are the flags shared by the commands acting on several repairs or schedules at once.
+type bulkOptions struct {
+	yes         *bool
+	concurrency *int
+}
+
+func newBulkOptions(fs *flag.FlagSet) bulkOptions {
+	return bulkOptions{
+		yes:         fs.Bool("yes", false, "Don't ask for confirmation"),
+		concurrency: fs.Int("concurrency", 4, "The number of requests made concurrently"),
+	}
+}
+
+// bulkResult is the outcome of an operation on one repair or schedule.
+type bulkResult struct {
+	ID    string `json:"id" yaml:"id"`
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// previewWriter is where the matched repairs or schedules are printed before asking for confirmation.
+// It must not mix with the report when it is machine readable.
+func previewWriter() io.Writer {
+	if flOutput != OutputText {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+func confirm(format string, args ...interface{}) (bool, error) {
+	fmt.Fprintf(os.Stderr, format+" [y/N] ", args...)
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, errors.E(errors.IO, "confirm", err)
+	}
+
+	answer := strings.ToLower(strings.TrimSpace(line))
+
+	return answer == "y" || answer == "yes", nil
+}
+
+// runBulk calls fn for each id with at most concurrency calls at the same time.
+// The ids not processed yet when ctx is cancelled are reported as failed.
+func runBulk(ids []string, concurrency int, fn func(id string) error) []bulkResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		results = make([]bulkResult, len(ids))
+		sem     = make(chan struct{}, concurrency)
+		wg      sync.WaitGroup
+	)
+
+	for i, id := range ids {
+		results[i].ID = id
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Error = ctx.Err().Error()
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := fn(id); err != nil {
+				results[i].Error = describeError(err)
+			}
+		}(i, id)
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+// reportBulk prints the results and returns an error if any operation failed.
+func reportBulk(results []bulkResult) error {
+	var failed int
+	for _, res := range results {
+		if res.Error != "" {
+			failed++
+		}
+	}
+
+	if flOutput != OutputText {
+		if err := writeOutput(os.Stdout, flOutput, results); err != nil {
+			return err
+		}
+	} else {
+		t := table{header: []string{"ID", "RESULT"}}
+		for _, res := range results {
+			if res.Error != "" {
+				t.addRow([]string{res.ID, "failed: " + res.Error}, color.New(color.FgRed))
+			} else {
+				t.addRow([]string{res.ID, "ok"}, color.New(color.FgGreen))
+			}
+		}
+		t.print(os.Stdout)
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d of %d operations failed", failed, len(results))
+	}
+
+	return nil
+}
+
+// bulkRepairs runs fn on every repair run matching filter after asking for confirmation.
+func bulkRepairs(action string, filter *repairFilter, opts bulkOptions, fn func(run reaper.RepairRun) error) error {
+	runs, err := filter.list()
+	if err != nil {
+		return err
+	}
+
+	if len(runs) == 0 {
+		fmt.Fprintln(os.Stderr, "no repair matched")
+		return nil
+	}
+
+	if err := printRunsTable(previewWriter(), runs, nil, true); err != nil {
+		return err
+	}
+
+	if !*opts.yes {
+		ok, err := confirm("%s these %d repairs?", action, len(runs))
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	byID := make(map[string]reaper.RepairRun, len(runs))
+	ids := make([]string, 0, len(runs))
+	for _, run := range runs {
+		byID[run.ID] = run
+		ids = append(ids, run.ID)
+	}
+
+	results := runBulk(ids, *opts.concurrency, func(id string) error {
+		return fn(byID[id])
+	})
+
+	return reportBulk(results)
+}
+
+// bulkSchedules runs fn on every repair schedule matching filter after asking for confirmation.
+func bulkSchedules(action string, filter *scheduleFilter, opts bulkOptions, fn func(sched reaper.RepairSchedule) error) error {
+	schedules, err := filter.list()
+	if err != nil {
+		return err
+	}
+
+	if len(schedules) == 0 {
+		fmt.Fprintln(os.Stderr, "no schedule matched")
+		return nil
+	}
+
+	if err := printSchedulesTable(previewWriter(), schedules, nil, true); err != nil {
+		return err
+	}
+
+	if !*opts.yes {
+		ok, err := confirm("%s these %d schedules?", action, len(schedules))
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	byID := make(map[string]reaper.RepairSchedule, len(schedules))
+	ids := make([]string, 0, len(schedules))
+	for _, sched := range schedules {
+		byID[sched.ID] = sched
+		ids = append(ids, sched.ID)
+	}
+
+	results := runBulk(ids, *opts.concurrency, func(id string) error {
+		return fn(byID[id])
+	})
+
+	return reportBulk(results)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/vrischmann/happyreaper/errors"
+	"github.com/vrischmann/happyreaper/reaper"
+)
+
+// activation is a future activation of a repair schedule.
+// Segments is the total number of segments, or the number per node with newer Reaper versions.
+type activation struct {
+	Time       time.Time `json:"time" yaml:"time"`
+	ScheduleID string    `json:"schedule_id" yaml:"schedule_id"`
+	Cluster    string    `json:"cluster_name" yaml:"cluster_name"`
+	Keyspace   string    `json:"keyspace_name" yaml:"keyspace_name"`
+	Tables     []string  `json:"column_families" yaml:"column_families"`
+	Segments   int       `json:"segment_count" yaml:"segment_count"`
+}
+
+// projectActivations returns the activations of the ACTIVE schedules between from and until, ordered by time.
+// A schedule is activated on its next activation and then every ScheduledDaysBetween days.
+func projectActivations(schedules []reaper.RepairSchedule, from, until time.Time) []activation {
+	var res []activation
+	for _, sched := range schedules {
+		if sched.State != reaper.SActive || sched.NextActivation == nil {
+			continue
+		}
+
+		for t := *sched.NextActivation; t.Before(until); t = t.AddDate(0, 0, sched.ScheduledDaysBetween) {
+			if !t.Before(from) {
+				res = append(res, activation{
+					Time:       t,
+					ScheduleID: sched.ID,
+					Cluster:    sched.ClusterName,
+					Keyspace:   sched.KeyspaceName,
+					Tables:     sched.ColumnFamilies,
+					Segments:   segmentCount(sched),
+				})
+			}
+			if sched.ScheduledDaysBetween <= 0 {
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Time.Before(res[j].Time) })
+
+	return res
+}
+
+// startOfDay returns midnight of the day of t, in the location of t.
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// printCalendar prints one line per day between from and until for each cluster.
+// The days when more than maxPerDay keyspaces start repairing are highlighted.
+func printCalendar(w io.Writer, acts []activation, from, until time.Time, maxPerDay int) {
+	byCluster := make(map[string]map[string][]activation)
+	for _, act := range acts {
+		days, ok := byCluster[act.Cluster]
+		if !ok {
+			days = make(map[string][]activation)
+			byCluster[act.Cluster] = days
+		}
+		day := act.Time.In(from.Location()).Format(myTimeLayout)
+		days[day] = append(days[day], act)
+	}
+
+	clusters := make([]string, 0, len(byCluster))
+	for name := range byCluster {
+		clusters = append(clusters, name)
+	}
+	sort.Strings(clusters)
+
+	if len(clusters) == 0 {
+		fmt.Fprintln(w, "No activation in this period.")
+		return
+	}
+
+	for i, name := range clusters {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "Cluster %s:\n\n", name)
+
+		t := table{header: []string{"DAY", "", "KEYSPACES", "LOAD", "REPAIRS"}}
+		for day := startOfDay(from); day.Before(until); day = day.AddDate(0, 0, 1) {
+			dayActs := byCluster[name][day.Format(myTimeLayout)]
+
+			var (
+				keyspaces = make(map[string]bool)
+				repairs   []string
+			)
+			for _, act := range dayActs {
+				keyspaces[act.Keyspace] = true
+				repairs = append(repairs, act.Time.In(from.Location()).Format("15:04")+" "+act.Keyspace+tablesSuffix(act.Tables))
+			}
+
+			// The marker shows the busy days when the output has no colors.
+			var (
+				c      *color.Color
+				marker string
+			)
+			if len(keyspaces) > maxPerDay {
+				c, marker = color.New(color.FgRed), " !"
+			}
+
+			t.addRow([]string{
+				day.Format(myTimeLayout), day.Format("Mon") + marker,
+				strconv.Itoa(len(keyspaces)), strings.Repeat("#", len(keyspaces)),
+				strings.Join(repairs, ", "),
+			}, c)
+		}
+		t.print(w)
+	}
+}
+
+// icsEscape escapes a text value of an iCalendar file.
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// foldICSLine folds a content line of an iCalendar file so that no line is longer than 75 octets,
+// including the space starting the continuation lines. Multi-byte characters are never split.
+func foldICSLine(line string) string {
+	var buf strings.Builder
+
+	limit := 75
+	for len(line) > limit {
+		i := limit
+		for i > 0 && !utf8.RuneStart(line[i]) {
+			i--
+		}
+		buf.WriteString(line[:i] + "\r\n ")
+		line = line[i:]
+		limit = 74
+	}
+	buf.WriteString(line + "\r\n")
+
+	return buf.String()
+}
+
+// writeICSLine writes a content line of an iCalendar file, folded.
+func writeICSLine(w *bufio.Writer, line string) {
+	w.WriteString(foldICSLine(line))
+}
+
+// writeICS writes the activations as one hour events of an iCalendar file.
+func writeICS(w io.Writer, acts []activation, now time.Time) error {
+	const (
+		op     = "writeICS"
+		layout = "20060102T150405Z"
+	)
+
+	bw := bufio.NewWriter(w)
+
+	writeICSLine(bw, "BEGIN:VCALENDAR")
+	writeICSLine(bw, "VERSION:2.0")
+	writeICSLine(bw, "PRODID:-//happyreaper//calendar//EN")
+	writeICSLine(bw, "CALSCALE:GREGORIAN")
+	for _, act := range acts {
+		key := scheduleKey(act.Cluster, act.Keyspace, act.Tables)
+
+		// Zero if Reaper doesn't report the number of segments.
+		description := "Schedule " + act.ScheduleID
+		if act.Segments > 0 {
+			description += fmt.Sprintf(", %d segments", act.Segments)
+		}
+
+		writeICSLine(bw, "BEGIN:VEVENT")
+		writeICSLine(bw, "UID:"+act.ScheduleID+"-"+act.Time.UTC().Format("20060102")+"@happyreaper")
+		writeICSLine(bw, "DTSTAMP:"+now.UTC().Format(layout))
+		writeICSLine(bw, "DTSTART:"+act.Time.UTC().Format(layout))
+		writeICSLine(bw, "DURATION:PT1H")
+		writeICSLine(bw, "SUMMARY:"+icsEscape("Repair of "+key))
+		writeICSLine(bw, "DESCRIPTION:"+icsEscape(description))
+		writeICSLine(bw, "END:VEVENT")
+	}
+	writeICSLine(bw, "END:VCALENDAR")
+
+	if err := bw.Flush(); err != nil {
+		return errors.E(errors.IO, op, err)
+	}
+
+	return nil
+}
+
+func calendar(args []string) error {
+	var (
+		fs          = flag.NewFlagSet("calendar", flag.ContinueOnError)
+		flCluster   = fs.String("cluster", "", "Only show the schedules of this cluster")
+		flDays      = fs.Int("days", 60, "The number of days to show")
+		flMaxPerDay = fs.Int("max-per-day", 2, "Highlight the days when more keyspaces than this start repairing in a cluster")
+		flICS       = fs.String("ics", "", "Also write the activations to this iCalendar file")
+	)
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if *flDays <= 0 {
+		return errors.Str("please provide a positive number of days")
+	}
+
+	schedules, err := callListSchedules(*flCluster, "")
+	if err != nil {
+		return err
+	}
+
+	var (
+		now   = time.Now()
+		from  = startOfDay(now)
+		until = from.AddDate(0, 0, *flDays)
+		acts  = projectActivations(schedules, from, until)
+	)
+
+	if *flICS != "" {
+		f, err := os.OpenFile(*flICS, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return errors.E(errors.IO, "calendar", err)
+		}
+		if err := writeICS(f, acts, now); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return errors.E(errors.IO, "calendar", err)
+		}
+	}
+
+	if flOutput != OutputText {
+		return writeOutput(os.Stdout, flOutput, acts)
+	}
+
+	printCalendar(os.Stdout, acts, from, until, *flMaxPerDay)
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/vrischmann/happyreaper/reaper"
+)
+
+func TestProjectActivations(t *testing.T) {
+	day := func(n int) time.Time { return testNow.AddDate(0, 0, n) }
+	at := func(t time.Time) *time.Time { return &t }
+
+	weekly := testSchedule("w", "ks1")
+	weekly.ScheduledDaysBetween, weekly.NextActivation = 7, at(day(1))
+
+	biweekly := testSchedule("b", "ks2")
+	biweekly.NextActivation = at(day(3))
+
+	paused := testSchedule("p", "ks3")
+	paused.State, paused.NextActivation = reaper.SPaused, at(day(1))
+
+	noActivation := testSchedule("n", "ks4")
+
+	zeroDays := testSchedule("z", "ks5")
+	zeroDays.ScheduledDaysBetween, zeroDays.NextActivation = 0, at(day(2))
+
+	overdue := testSchedule("o", "ks6")
+	overdue.ScheduledDaysBetween, overdue.NextActivation = 7, at(day(-1))
+
+	type act struct {
+		id  string
+		day int
+	}
+
+	testCases := []struct {
+		name      string
+		schedules []reaper.RepairSchedule
+		exp       []act
+	}{
+		{"repeats every cycle", []reaper.RepairSchedule{weekly}, []act{{"w", 1}, {"w", 8}, {"w", 15}}},
+		{"ordered by time", []reaper.RepairSchedule{biweekly, weekly}, []act{{"w", 1}, {"b", 3}, {"w", 8}, {"w", 15}, {"b", 17}}},
+		{"only active schedules", []reaper.RepairSchedule{paused, noActivation}, nil},
+		{"zero days between", []reaper.RepairSchedule{zeroDays}, []act{{"z", 2}}},
+		{"starts before the period", []reaper.RepairSchedule{overdue}, []act{{"o", 6}, {"o", 13}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var res []act
+			for _, a := range projectActivations(tc.schedules, testNow, day(20)) {
+				res = append(res, act{a.ScheduleID, int(a.Time.Sub(testNow).Hours() / 24)})
+			}
+			if !reflect.DeepEqual(res, tc.exp) {
+				t.Errorf("got %v, expected %v", res, tc.exp)
+			}
+		})
+	}
+}
+
+func TestProjectActivationsSegments(t *testing.T) {
+	next := testNow.AddDate(0, 0, 1)
+
+	perNode := testSchedule("p", "ks1")
+	perNode.SegmentCount, perNode.SegmentCountPerNode = 0, 64
+
+	unknown := testSchedule("u", "ks2")
+	unknown.SegmentCount = 0
+
+	testCases := []struct {
+		name  string
+		sched reaper.RepairSchedule
+		exp   int
+	}{
+		{"total", testSchedule("t", "ks3"), 200},
+		{"per node", perNode, 64},
+		{"unknown", unknown, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.sched.NextActivation = &next
+
+			acts := projectActivations([]reaper.RepairSchedule{tc.sched}, testNow, testNow.AddDate(0, 0, 2))
+			if len(acts) != 1 || acts[0].Segments != tc.exp {
+				t.Errorf("got %+v, expected one activation with %d segments", acts, tc.exp)
+			}
+		})
+	}
+}
+
+func TestFoldICSLine(t *testing.T) {
+	testCases := []struct {
+		name string
+		line string
+	}{
+		{"short", "SUMMARY:Repair of c1/ks1"},
+		{"exactly 75", "SUMMARY:" + strings.Repeat("a", 67)},
+		{"long", "DESCRIPTION:" + strings.Repeat("a", 200)},
+		{"multi-byte", "SUMMARY:" + strings.Repeat("é", 100)},
+		{"multi-byte at the limit", "SUMMARY:" + strings.Repeat("a", 66) + strings.Repeat("日本", 40)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := foldICSLine(tc.line)
+
+			if !strings.HasSuffix(res, "\r\n") {
+				t.Fatalf("got %q, expected a CRLF at the end", res)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(res, "\r\n"), "\r\n")
+			for i, l := range lines {
+				if len(l) > 75 {
+					t.Errorf("line %d has %d octets", i, len(l))
+				}
+				if i > 0 && !strings.HasPrefix(l, " ") {
+					t.Errorf("continuation line %d doesn't start with a space", i)
+				}
+				if !utf8.ValidString(l) {
+					t.Errorf("line %d splits a character: %q", i, l)
+				}
+			}
+
+			// Unfolding gives back the line.
+			if unfolded := strings.Replace(strings.TrimSuffix(res, "\r\n"), "\r\n ", "", -1); unfolded != tc.line {
+				t.Errorf("got %q once unfolded, expected %q", unfolded, tc.line)
+			}
+		})
+	}
+}
+
+func TestICSEscape(t *testing.T) {
+	if got, exp := icsEscape("a,b;c\\d\ne"), `a\,b\;c\\d\ne`; got != exp {
+		t.Errorf("got %q, expected %q", got, exp)
+	}
+}
+
+func TestPrintCalendarMarksBusyDays(t *testing.T) {
+	acts := []activation{
+		{Time: testNow.Add(time.Hour), Cluster: "c1", Keyspace: "ks1"},
+		{Time: testNow.Add(2 * time.Hour), Cluster: "c1", Keyspace: "ks2"},
+		{Time: testNow.Add(26 * time.Hour), Cluster: "c1", Keyspace: "ks3"},
+	}
+
+	var buf bytes.Buffer
+	printCalendar(&buf, acts, startOfDay(testNow), startOfDay(testNow).AddDate(0, 0, 2), 1)
+
+	var marked []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, " !") {
+			marked = append(marked, strings.Fields(line)[0])
+		}
+	}
+	if exp := []string{"2026-10-16"}; !reflect.DeepEqual(marked, exp) {
+		t.Errorf("got marked days %v, expected %v", marked, exp)
+	}
+}
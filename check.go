@@ -0,0 +1,342 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vrischmann/happyreaper/reaper"
+)
+
+// checkStatus is the status of a check, its value is the exit status expected by Nagios and Icinga.
+type checkStatus int
+
+const (
+	checkOK checkStatus = iota
+	checkWarning
+	checkCritical
+	checkUnknown
+)
+
+func (s checkStatus) String() string {
+	switch s {
+	case checkOK:
+		return "OK"
+	case checkWarning:
+		return "WARNING"
+	case checkCritical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// checkResult accumulates the problems found by the rules, its status is the worst one.
+type checkResult struct {
+	status   checkStatus
+	messages []string
+	perfdata []string
+}
+
+func (r *checkResult) add(status checkStatus, format string, args ...interface{}) {
+	if status == checkOK {
+		return
+	}
+	if status > r.status {
+		r.status = status
+	}
+	r.messages = append(r.messages, fmt.Sprintf(format, args...))
+}
+
+func (r *checkResult) addPerfdata(label string, value int, warning, critical string) {
+	r.perfdata = append(r.perfdata, fmt.Sprintf("%s=%d;%s;%s;0", label, value, warning, critical))
+}
+
+func (r *checkResult) String() string {
+	var buf strings.Builder
+
+	buf.WriteString("REAPER " + r.status.String() + " - ")
+	if len(r.messages) > 0 {
+		buf.WriteString(strings.Join(r.messages, ", "))
+	} else {
+		buf.WriteString("no problem found")
+	}
+	if len(r.perfdata) > 0 {
+		buf.WriteString(" | " + strings.Join(r.perfdata, " "))
+	}
+
+	return buf.String()
+}
+
+// thresholdStatus returns the status of value given the warning and critical thresholds, a zero threshold is disabled.
+func thresholdStatus(value, warning, critical float64) checkStatus {
+	switch {
+	case critical > 0 && value >= critical:
+		return checkCritical
+	case warning > 0 && value >= warning:
+		return checkWarning
+	default:
+		return checkOK
+	}
+}
+
+// thresholdString formats a threshold for the perfdata, a zero threshold is left empty.
+func thresholdString(v int) string {
+	if v <= 0 {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// lastProgress returns the last time a segment of run was repaired,
+// or the start time of the run if no segment was repaired yet.
+func lastProgress(run reaper.RepairRun) (*time.Time, error) {
+	segments, err := client.ListSegments(ctx, run.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := run.StartTime
+	for _, seg := range segments {
+		if seg.State != reaper.SegDone || seg.EndTime == nil {
+			continue
+		}
+		if res == nil || seg.EndTime.After(*res) {
+			res = seg.EndTime
+		}
+	}
+
+	return res, nil
+}
+
+func keyspaceKey(cluster, keyspace string) string { return cluster + "/" + keyspace }
+
+func check(args []string) error {
+	var (
+		fs                   = flag.NewFlagSet("check", flag.ContinueOnError)
+		flCluster            = fs.String("cluster", "", "Only check this cluster")
+		flKeyspace           = fs.String("keyspace", "", "Only check this keyspace")
+		flErrorWindow        = fs.Duration("error-window", 24*time.Hour, "Only count the runs in ERROR which ended during this window, 0 means all of them")
+		flErrorWarning       = fs.Int("error-warning", 0, "Warn if at least this number of runs are in ERROR, 0 disables it")
+		flErrorCritical      = fs.Int("error-critical", 1, "Critical if at least this number of runs are in ERROR, 0 disables it")
+		flStalledWarning     = fs.Duration("stalled-warning", 6*time.Hour, "Warn if a RUNNING run made no progress for this long, 0 disables it")
+		flStalledCritical    = fs.Duration("stalled-critical", 24*time.Hour, "Critical if a RUNNING run made no progress for this long, 0 disables it")
+		flUnrepairedWarning  = fs.Int("unrepaired-warning", 0, "Warn if a keyspace has no DONE run for this number of days, 0 disables it")
+		flUnrepairedCritical = fs.Int("unrepaired-critical", 0, "Critical if a keyspace has no DONE run for this number of days, 0 disables it")
+		flOverdueWarning     = fs.Duration("overdue-warning", time.Hour, "Warn if the next activation of an ACTIVE schedule is past for this long, 0 disables it")
+		flOverdueCritical    = fs.Duration("overdue-critical", 24*time.Hour, "Critical if the next activation of an ACTIVE schedule is past for this long, 0 disables it")
+	)
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		exitCheckSetup(err)
+	}
+
+	res, err := runChecks(checkParams{
+		cluster:            *flCluster,
+		keyspace:           *flKeyspace,
+		errorWindow:        *flErrorWindow,
+		errorWarning:       *flErrorWarning,
+		errorCritical:      *flErrorCritical,
+		stalledWarning:     *flStalledWarning,
+		stalledCritical:    *flStalledCritical,
+		unrepairedWarning:  time.Duration(*flUnrepairedWarning) * 24 * time.Hour,
+		unrepairedCritical: time.Duration(*flUnrepairedCritical) * 24 * time.Hour,
+		overdueWarning:     *flOverdueWarning,
+		overdueCritical:    *flOverdueCritical,
+	}, time.Now())
+	if err != nil {
+		res = &checkResult{status: checkUnknown}
+		res.add(checkUnknown, "%s", describeError(err))
+	}
+
+	fmt.Println(res)
+
+	if res.status == checkOK {
+		return nil
+	}
+
+	// The status line is the report, main must not log anything else.
+	return &exitStatusError{code: int(res.status)}
+}
+
+// exitCheckSetup prints the status line of check when it couldn't even run, for example because of an invalid flag or TLS file,
+// and exits with UNKNOWN since Nagios would read the usual exit status 1 as WARNING.
+func exitCheckSetup(err error) {
+	res := &checkResult{status: checkUnknown}
+	res.add(checkUnknown, "%s", describeError(err))
+
+	fmt.Println(res)
+
+	os.Exit(int(checkUnknown))
+}
+
+type checkParams struct {
+	cluster  string
+	keyspace string
+
+	errorWindow        time.Duration
+	errorWarning       int
+	errorCritical      int
+	stalledWarning     time.Duration
+	stalledCritical    time.Duration
+	unrepairedWarning  time.Duration
+	unrepairedCritical time.Duration
+	overdueWarning     time.Duration
+	overdueCritical    time.Duration
+}
+
+func (p checkParams) match(cluster, keyspace string) bool {
+	return (p.cluster == "" || p.cluster == cluster) && (p.keyspace == "" || p.keyspace == keyspace)
+}
+
+func runChecks(p checkParams, now time.Time) (*checkResult, error) {
+	allRuns, err := client.ListRepairRuns(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	allSchedules, err := callListSchedules(p.cluster, p.keyspace)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		runs      []reaper.RepairRun
+		schedules []reaper.RepairSchedule
+	)
+	for _, run := range allRuns {
+		if p.match(run.ClusterName, run.KeyspaceName) {
+			runs = append(runs, run)
+		}
+	}
+	for _, sched := range allSchedules {
+		if p.match(sched.ClusterName, sched.KeyspaceName) {
+			schedules = append(schedules, sched)
+		}
+	}
+
+	res := new(checkResult)
+
+	// Runs in ERROR.
+	var errorIDs []string
+	for _, run := range runs {
+		if run.State != reaper.Error {
+			continue
+		}
+		if p.errorWindow > 0 && run.EndTime != nil && now.Sub(*run.EndTime) > p.errorWindow {
+			continue
+		}
+		errorIDs = append(errorIDs, run.ID)
+	}
+	res.add(thresholdStatus(float64(len(errorIDs)), float64(p.errorWarning), float64(p.errorCritical)),
+		"%d repair runs in ERROR (%s)", len(errorIDs), strings.Join(errorIDs, " "))
+	res.addPerfdata("error_runs", len(errorIDs), thresholdString(p.errorWarning), thresholdString(p.errorCritical))
+
+	// RUNNING runs without progress.
+	var running, stalled int
+	for _, run := range runs {
+		if run.State != reaper.Running {
+			continue
+		}
+		running++
+
+		if p.stalledWarning <= 0 && p.stalledCritical <= 0 {
+			continue
+		}
+
+		last, err := lastProgress(run)
+		if err != nil {
+			return nil, err
+		}
+		if last == nil {
+			continue
+		}
+
+		idle := now.Sub(*last)
+		status := thresholdStatus(idle.Seconds(), p.stalledWarning.Seconds(), p.stalledCritical.Seconds())
+		if status != checkOK {
+			stalled++
+		}
+		res.add(status, "repair run %s of %s made no progress for %s",
+			run.ID, keyspaceKey(run.ClusterName, run.KeyspaceName), idle.Truncate(time.Minute))
+	}
+	res.addPerfdata("running_runs", running, "", "")
+	res.addPerfdata("stalled_runs", stalled, "", "")
+
+	// Keyspaces without a DONE run, the keyspaces considered are the ones with an ACTIVE schedule or a repair run.
+	if p.unrepairedWarning > 0 || p.unrepairedCritical > 0 {
+		lastDone := make(map[string]*time.Time)
+		for _, sched := range schedules {
+			if sched.State == reaper.SActive {
+				lastDone[keyspaceKey(sched.ClusterName, sched.KeyspaceName)] = nil
+			}
+		}
+		for _, run := range runs {
+			key := keyspaceKey(run.ClusterName, run.KeyspaceName)
+			last := lastDone[key]
+			if run.State == reaper.Done && run.EndTime != nil && (last == nil || run.EndTime.After(*last)) {
+				last = run.EndTime
+			}
+			lastDone[key] = last
+		}
+
+		keys := make([]string, 0, len(lastDone))
+		for key := range lastDone {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var unrepaired int
+		for _, key := range keys {
+			last := lastDone[key]
+			if last == nil {
+				status := checkCritical
+				if p.unrepairedCritical <= 0 {
+					status = checkWarning
+				}
+				unrepaired++
+				res.add(status, "keyspace %s was never repaired", key)
+				continue
+			}
+
+			age := now.Sub(*last)
+			status := thresholdStatus(age.Seconds(), p.unrepairedWarning.Seconds(), p.unrepairedCritical.Seconds())
+			if status != checkOK {
+				unrepaired++
+			}
+			res.add(status, "keyspace %s was last repaired %s ago", key, age.Truncate(time.Minute))
+		}
+		res.addPerfdata("unrepaired_keyspaces", unrepaired, "", "")
+	}
+
+	// ACTIVE schedules which should have been activated already.
+	var active, overdue int
+	for _, sched := range schedules {
+		if sched.State != reaper.SActive {
+			continue
+		}
+		active++
+
+		if sched.NextActivation == nil || !sched.NextActivation.Before(now) {
+			continue
+		}
+
+		late := now.Sub(*sched.NextActivation)
+		status := thresholdStatus(late.Seconds(), p.overdueWarning.Seconds(), p.overdueCritical.Seconds())
+		if status != checkOK {
+			overdue++
+		}
+		res.add(status, "schedule %s of %s is overdue by %s",
+			sched.ID, keyspaceKey(sched.ClusterName, sched.KeyspaceName), late.Truncate(time.Minute))
+	}
+	res.addPerfdata("active_schedules", active, "", "")
+	res.addPerfdata("overdue_schedules", overdue, "", "")
+
+	return res, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/vrischmann/happyreaper/errors"
+	"github.com/vrischmann/happyreaper/reaper"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// loadEnv fills the connection flags not provided on the command line
+// or by the configuration context with their REAPER_* environment variable.
+func loadEnv() error {
+	if len(flReaperHost) == 0 {
+		val := os.Getenv("REAPER_HOST")
+		if val != "" {
+			if err := flReaperHost.Set(val); err != nil {
+				return errors.Errorf("REAPER_HOST value is invalid. err=%v", err)
+			}
+		}
+	}
+
+	envString := func(s *string, name string) {
+		if *s == "" {
+			*s = os.Getenv(name)
+		}
+	}
+
+	envString(flScheme, "REAPER_SCHEME")
+	envString(&flTLS.CAFile, "REAPER_CA_FILE")
+	envString(&flTLS.CertFile, "REAPER_CERT_FILE")
+	envString(&flTLS.KeyFile, "REAPER_KEY_FILE")
+	envString(&flCreds.Username, "REAPER_USERNAME")
+	envString(&flCreds.Password, "REAPER_PASSWORD")
+	envString(flCredsFile, "REAPER_CREDENTIALS_FILE")
+
+	if val := os.Getenv("REAPER_AUTH"); flAuth == "" && val != "" {
+		if err := flAuth.Set(val); err != nil {
+			return errors.Errorf("REAPER_AUTH value is invalid. err=%v", err)
+		}
+	}
+
+	if val := os.Getenv("REAPER_INSECURE_SKIP_VERIFY"); !flTLS.InsecureSkipVerify && val != "" {
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return errors.Errorf("REAPER_INSECURE_SKIP_VERIFY value is invalid. err=%v", err)
+		}
+		flTLS.InsecureSkipVerify = b
+	}
+
+	// The credentials file is only used if no credentials were provided otherwise.
+	if *flCredsFile != "" && flCreds.IsZero() {
+		creds, err := readCredentialsFile(*flCredsFile)
+		if err != nil {
+			return err
+		}
+		flCreds = creds
+	}
+
+	return nil
+}
+
+func readCredentialsFile(path string) (reaper.Credentials, error) {
+	const op = "readCredentialsFile"
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return reaper.Credentials{}, errors.E(errors.IO, op, err)
+	}
+
+	var res reaper.Credentials
+	if err := yaml.Unmarshal(data, &res); err != nil {
+		return reaper.Credentials{}, errors.E(errors.Invalid, op, err)
+	}
+
+	return res, nil
+}
+
+// newClient creates the client shared by all commands from the connection flags.
+func newClient() (*reaper.Client, error) {
+	return newClientFor(flReaperHost, *flScheme, flTLS, flCreds, flAuth)
+}
+
+// newClientFor creates a client for another Reaper than the one of the connection flags,
+// only the timeout, retries and verbose flags are shared.
+func newClientFor(hosts []string, scheme string, tlsOptions reaper.TLSOptions, creds reaper.Credentials, auth reaper.AuthMethod) (*reaper.Client, error) {
+	if scheme == "" {
+		scheme = "http"
+		if !tlsOptions.IsZero() {
+			scheme = "https"
+		}
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+	}
+
+	switch scheme {
+	case "http":
+	case "https":
+		conf, err := tlsOptions.Config()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = conf
+	default:
+		return nil, errors.Errorf("invalid scheme %q", scheme)
+	}
+
+	conf := reaper.Config{
+		Scheme:      scheme,
+		HTTPClient:  &http.Client{Transport: transport},
+		Credentials: creds,
+		Auth:        auth,
+		Timeout:     *flTimeout,
+		Retries:     *flRetries,
+	}
+	if *flVerbose {
+		conf.Logf = log.Printf
+	}
+
+	return reaper.NewClient(hosts, conf), nil
+}
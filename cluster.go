@@ -1,42 +1,27 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
-	"net/http"
+	"os"
 
 	"github.com/fatih/color"
 	"github.com/vrischmann/flagutil"
 	"github.com/vrischmann/happyreaper/errors"
+	"github.com/vrischmann/happyreaper/reaper"
 )
 
-type Cluster struct {
-	Name            string           `json:"name"`
-	SeedHosts       []string         `json:"seed_hosts"`
-	RepairRuns      []RepairRun      `json:"repair_runs"`
-	RepairSchedules []RepairSchedule `json:"repair_schedules"`
-}
-
 func listClusters(args []string) error {
-	const op = "listClusters"
-
-	resp, err := http.Get(makeURL("/cluster"))
+	res, err := client.ListClusters(ctx)
 	if err != nil {
-		return errors.E(errors.IO, op, err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	dec := json.NewDecoder(resp.Body)
-	var res []string
-
-	if err := dec.Decode(&res); err != nil {
-		return errors.E(errors.IO, op, err)
+	if flOutput != OutputText {
+		return writeOutput(os.Stdout, flOutput, res)
 	}
 
-	fmt.Println("All clusters:\n")
+	fmt.Print("All clusters:\n\n")
 	for _, cl := range res {
 		fmt.Println(cl)
 	}
@@ -48,20 +33,18 @@ type printClusterParams struct {
 	ShowRuns            bool
 	ShowSchedules       bool
 	FilterCFs           []string
-	FilterRunState      RunState
-	FilterScheduleState ScheduleState
+	FilterRunState      reaper.RunState
+	FilterScheduleState reaper.ScheduleState
 }
 
-func printCluster(c Cluster, params printClusterParams) {
-	color.Yellow("Seeds:\n")
-	for _, seed := range c.SeedHosts {
-		fmt.Println(seed)
+// filterCluster returns a copy of c containing only the runs and schedules matching params.
+func filterCluster(c reaper.Cluster, params printClusterParams) reaper.Cluster {
+	res := reaper.Cluster{
+		Name:      c.Name,
+		SeedHosts: c.SeedHosts,
 	}
-	fmt.Println("")
 
 	if params.ShowRuns {
-		var headerPrinted bool
-
 		for _, run := range c.RepairRuns {
 			if len(params.FilterCFs) > 0 {
 				if !contains(run.ColumnFamilies, params.FilterCFs) {
@@ -73,18 +56,11 @@ func printCluster(c Cluster, params printClusterParams) {
 				continue
 			}
 
-			if !headerPrinted {
-				color.Yellow("Runs:\n")
-				headerPrinted = true
-			}
-
-			fmt.Printf("%+v\n", run)
+			res.RepairRuns = append(res.RepairRuns, run)
 		}
 	}
 
 	if params.ShowSchedules {
-		var headerPrinted bool
-
 		for _, sc := range c.RepairSchedules {
 			if len(params.FilterCFs) > 0 {
 				if !contains(sc.ColumnFamilies, params.FilterCFs) {
@@ -96,25 +72,45 @@ func printCluster(c Cluster, params printClusterParams) {
 				continue
 			}
 
-			if !headerPrinted {
-				color.Yellow("Schedules:\n")
-			}
+			res.RepairSchedules = append(res.RepairSchedules, sc)
+		}
+	}
+
+	return res
+}
+
+func printCluster(c reaper.Cluster, params printClusterParams) {
+	c = filterCluster(c, params)
+
+	color.Yellow("Seeds:\n")
+	for _, seed := range c.SeedHosts {
+		fmt.Println(seed)
+	}
+	fmt.Println("")
+
+	if len(c.RepairRuns) > 0 {
+		color.Yellow("Runs:\n")
+		for _, run := range c.RepairRuns {
+			fmt.Printf("%+v\n", run)
+		}
+	}
 
+	if len(c.RepairSchedules) > 0 {
+		color.Yellow("Schedules:\n")
+		for _, sc := range c.RepairSchedules {
 			fmt.Printf("%+v\n", sc)
 		}
 	}
 }
 
 func viewCluster(args []string) error {
-	const op = "viewCluster"
-
 	var (
 		fs              = flag.NewFlagSet("view-cluster", flag.ContinueOnError)
 		flShowRuns      = fs.Bool("runs", true, "Show all runs from this cluster")
 		flShowSchedules = fs.Bool("schedules", false, "Show all schedules from this cluster")
 		flCFs           flagutil.Strings
-		flRunState      RunState
-		flScheduleState ScheduleState
+		flRunState      reaper.RunState
+		flScheduleState reaper.ScheduleState
 	)
 
 	fs.Var(&flCFs, "cf", "Filter by column families")
@@ -135,25 +131,9 @@ func viewCluster(args []string) error {
 
 	flName := fs.Arg(0)
 
-	resp, err := http.Get(makeURL("/cluster/" + flName))
+	res, err := client.GetCluster(ctx, flName)
 	if err != nil {
-		return errors.E(errors.IO, op, err)
-	}
-	defer resp.Body.Close()
-
-	var buf bytes.Buffer
-	rd := io.TeeReader(resp.Body, &buf)
-
-	if resp.StatusCode != http.StatusOK {
-		io.Copy(&buf, rd)
-		return errors.Str(buf.String())
-	}
-
-	var res Cluster
-	dec := json.NewDecoder(rd)
-
-	if err := dec.Decode(&res); err != nil {
-		return errors.E(errors.IO, op, err)
+		return err
 	}
 
 	params := printClusterParams{
@@ -164,6 +144,17 @@ func viewCluster(args []string) error {
 		FilterScheduleState: flScheduleState,
 	}
 
+	switch {
+	case flOutput == OutputCSV && params.ShowRuns == params.ShowSchedules:
+		return errors.Str("csv output needs exactly one of -runs or -schedules")
+	case flOutput == OutputCSV && params.ShowRuns:
+		return writeOutput(os.Stdout, flOutput, filterCluster(res, params).RepairRuns)
+	case flOutput == OutputCSV:
+		return writeOutput(os.Stdout, flOutput, filterCluster(res, params).RepairSchedules)
+	case flOutput != OutputText:
+		return writeOutput(os.Stdout, flOutput, filterCluster(res, params))
+	}
+
 	fmt.Printf("Cluster %q:\n\n", flName)
 	printCluster(res, params)
 
@@ -171,8 +162,6 @@ func viewCluster(args []string) error {
 }
 
 func addCluster(args []string) error {
-	const op = "addCluster"
-
 	var (
 		fs     = flag.NewFlagSet("add-cluster", flag.ContinueOnError)
 		flSeed = fs.String("seed", "", "The seed host")
@@ -190,30 +179,157 @@ func addCluster(args []string) error {
 		return errors.Str("please provide a seed host")
 	}
 
-	resp, err := http.Post(makeURL("/cluster?seedHost="+*flSeed), "application/json", nil)
+	res, err := client.AddCluster(ctx, *flSeed)
 	if err != nil {
-		return errors.E(errors.IO, op, err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	var buf bytes.Buffer
-	rd := io.TeeReader(resp.Body, &buf)
+	color.Yellow("Cluster %s correctly added", res.Name)
+
+	printCluster(res, printClusterParams{})
 
-	if resp.StatusCode != http.StatusOK {
-		io.Copy(&buf, rd)
-		return errors.Str(buf.String())
+	return nil
+}
+
+func updateClusterSeeds(args []string) error {
+	var (
+		fs      = flag.NewFlagSet("update-cluster-seeds", flag.ContinueOnError)
+		flSeeds flagutil.Strings
+	)
+
+	fs.Var(&flSeeds, "seed", "The new seed hosts (comma separated list)")
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
 	}
 
-	var res Cluster
-	dec := json.NewDecoder(rd)
+	if fs.NArg() < 1 {
+		return errors.Str("please provide a cluster name")
+	}
+	if len(flSeeds) == 0 {
+		return errors.Str("please provide a seed host")
+	}
+
+	flName := fs.Arg(0)
 
-	if err := dec.Decode(&res); err != nil {
-		return errors.E(errors.IO, op, err)
+	res, err := client.UpdateClusterSeeds(ctx, flName, flSeeds)
+	if err != nil {
+		return err
 	}
 
-	color.Yellow("Cluster %s correctly added", res.Name)
+	color.Yellow("Seeds of cluster %s correctly updated", flName)
 
 	printCluster(res, printClusterParams{})
 
 	return nil
 }
+
+func deleteCluster(args []string) error {
+	var (
+		fs      = flag.NewFlagSet("delete-cluster", flag.ContinueOnError)
+		flForce = fs.Bool("force", false, "Delete the cluster even if it has repairs or schedules")
+		flPause = fs.Bool("pause", false, "Pause the running repairs and the active schedules first, then delete the cluster with -force")
+		flPurge = fs.Bool("purge", false, "Delete all the repairs and schedules of the cluster first, whatever their state")
+		flYes   = fs.Bool("yes", false, "Don't ask for confirmation before purging")
+	)
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if fs.NArg() < 1 {
+		return errors.Str("please provide a cluster name")
+	}
+
+	flName := fs.Arg(0)
+
+	cl, err := client.GetCluster(ctx, flName)
+	if err != nil {
+		return err
+	}
+
+	var (
+		running []reaper.RepairRun
+		active  []reaper.RepairSchedule
+	)
+	for _, run := range cl.RepairRuns {
+		if run.State == reaper.Running {
+			running = append(running, run)
+		}
+	}
+	for _, sc := range cl.RepairSchedules {
+		if sc.State == reaper.SActive {
+			active = append(active, sc)
+		}
+	}
+
+	if (len(running) > 0 || len(active) > 0) && !*flForce && !*flPause && !*flPurge {
+		printCluster(cl, printClusterParams{
+			ShowRuns:            true,
+			ShowSchedules:       true,
+			FilterRunState:      reaper.Running,
+			FilterScheduleState: reaper.SActive,
+		})
+		return errors.Errorf("cluster %s has %d running repairs and %d active schedules, use -pause to pause them, -purge to delete them or -force to ignore them",
+			flName, len(running), len(active))
+	}
+
+	// Purging deletes the history of the cluster too, not only what is running.
+	if *flPurge && (len(cl.RepairRuns) > 0 || len(cl.RepairSchedules) > 0) && !*flYes {
+		printCluster(cl, printClusterParams{ShowRuns: true, ShowSchedules: true})
+
+		ok, err := confirm("Delete these %d repairs and %d schedules of cluster %s?", len(cl.RepairRuns), len(cl.RepairSchedules), flName)
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	if *flPause || *flPurge {
+		for _, run := range running {
+			if _, err := client.ChangeRepairRunState(ctx, run.ID, reaper.Paused); err != nil {
+				return err
+			}
+			color.Yellow("Repair %s paused", run.ID)
+		}
+		for _, sc := range active {
+			if _, err := client.ChangeScheduleState(ctx, sc.ID, reaper.SPaused); err != nil {
+				return err
+			}
+			color.Yellow("Schedule %s paused", sc.ID)
+		}
+	}
+
+	if *flPurge {
+		for _, run := range cl.RepairRuns {
+			if _, err := client.DeleteRepairRun(ctx, run.ID, run.Owner); err != nil {
+				return err
+			}
+			color.Yellow("Repair %s deleted", run.ID)
+		}
+		for _, sc := range cl.RepairSchedules {
+			if _, err := client.DeleteSchedule(ctx, sc.ID, sc.Owner); err != nil {
+				return err
+			}
+			color.Yellow("Schedule %s deleted", sc.ID)
+		}
+	}
+
+	// Reaper refuses to delete a cluster which still has paused repairs or schedules.
+	force := *flForce || *flPause
+
+	if err := client.DeleteCluster(ctx, flName, force); err != nil {
+		return err
+	}
+
+	color.Yellow("Cluster %s correctly deleted", flName)
+
+	return nil
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vrischmann/happyreaper/errors"
+	"github.com/vrischmann/happyreaper/reaper"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// configFile is the content of the configuration file, by default ~/.config/happyreaper/config.yaml.
+type configFile struct {
+	CurrentContext string                   `json:"current_context" yaml:"current_context"`
+	Contexts       map[string]reaperContext `json:"contexts" yaml:"contexts"`
+}
+
+// reaperContext holds everything needed to talk to one Reaper deployment.
+type reaperContext struct {
+	Hosts           []string           `json:"hosts" yaml:"hosts"`
+	Scheme          string             `json:"scheme,omitempty" yaml:"scheme,omitempty"`
+	TLS             reaper.TLSOptions  `json:"tls" yaml:"tls,omitempty"`
+	Credentials     reaper.Credentials `json:"credentials" yaml:"credentials,omitempty"`
+	CredentialsFile string             `json:"credentials_file,omitempty" yaml:"credentials_file,omitempty"`
+	Auth            string             `json:"auth,omitempty" yaml:"auth,omitempty"`
+	Defaults        contextDefaults    `json:"defaults" yaml:"defaults,omitempty"`
+}
+
+// contextDefaults are the default values of the flags used to create repairs and schedules.
+type contextDefaults struct {
+	Owner     string  `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Intensity float64 `json:"intensity,omitempty" yaml:"intensity,omitempty"`
+	Segments  int     `json:"segments,omitempty" yaml:"segments,omitempty"`
+}
+
+var (
+	config     configFile
+	configPath string
+
+	// defaults is overridden by the defaults of the current context, if any.
+	defaults = contextDefaults{
+		Intensity: 0.5,
+		Segments:  200,
+	}
+)
+
+func defaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "happyreaper", "config.yaml"), nil
+}
+
+// loadConfig reads the configuration file and fills the connection flags
+// not provided on the command line with the current context.
+//
+// A missing configuration file is not an error unless its path was given explicitly.
+func loadConfig() error {
+	const op = "loadConfig"
+
+	configPath = *flConfig
+	if configPath == "" {
+		configPath = os.Getenv("REAPER_CONFIG")
+	}
+	explicit := configPath != ""
+
+	if !explicit {
+		path, err := defaultConfigPath()
+		if err != nil {
+			// No home directory, nothing to load.
+			return nil
+		}
+		configPath = path
+	}
+
+	data, err := ioutil.ReadFile(configPath)
+	switch {
+	case os.IsNotExist(err) && !explicit:
+		data = nil
+	case err != nil:
+		return errors.E(errors.IO, op, err)
+	}
+
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return errors.E(errors.Invalid, op, errors.Errorf("unable to parse %s. err=%v", configPath, err))
+	}
+
+	name := selectedContext()
+	if name == "" {
+		return nil
+	}
+
+	rc, ok := config.Contexts[name]
+	if !ok {
+		return errors.Errorf("context %q not found in %s", name, configPath)
+	}
+
+	return applyContext(rc)
+}
+
+// applyContext fills the connection flags not provided on the command line with rc.
+func applyContext(rc reaperContext) error {
+	if len(flReaperHost) == 0 && len(rc.Hosts) > 0 {
+		if err := flReaperHost.Set(strings.Join(rc.Hosts, ",")); err != nil {
+			return errors.Errorf("context hosts are invalid. err=%v", err)
+		}
+	}
+
+	ctxString := func(s *string, val string) {
+		if *s == "" {
+			*s = val
+		}
+	}
+
+	ctxString(flScheme, rc.Scheme)
+	ctxString(&flTLS.CAFile, rc.TLS.CAFile)
+	ctxString(&flTLS.CertFile, rc.TLS.CertFile)
+	ctxString(&flTLS.KeyFile, rc.TLS.KeyFile)
+	ctxString(&flCreds.Username, rc.Credentials.Username)
+	ctxString(&flCreds.Password, rc.Credentials.Password)
+	ctxString(flCredsFile, rc.CredentialsFile)
+
+	if !flTLS.InsecureSkipVerify {
+		flTLS.InsecureSkipVerify = rc.TLS.InsecureSkipVerify
+	}
+
+	if flAuth == "" && rc.Auth != "" {
+		if err := flAuth.Set(rc.Auth); err != nil {
+			return errors.Errorf("context auth is invalid. err=%v", err)
+		}
+	}
+
+	if rc.Defaults.Owner != "" {
+		defaults.Owner = rc.Defaults.Owner
+	}
+	if rc.Defaults.Intensity > 0 {
+		defaults.Intensity = rc.Defaults.Intensity
+	}
+	if rc.Defaults.Segments > 0 {
+		defaults.Segments = rc.Defaults.Segments
+	}
+
+	return nil
+}
+
+// contextClient creates a client for the context name, the connection flags don't apply to it.
+func contextClient(name string) (*reaper.Client, error) {
+	rc, ok := config.Contexts[name]
+	if !ok {
+		return nil, errors.Errorf("context %q not found in %s", name, configPath)
+	}
+
+	creds := rc.Credentials
+	if rc.CredentialsFile != "" && creds.IsZero() {
+		var err error
+		if creds, err = readCredentialsFile(rc.CredentialsFile); err != nil {
+			return nil, err
+		}
+	}
+
+	var auth reaper.AuthMethod
+	if rc.Auth != "" {
+		if err := auth.Set(rc.Auth); err != nil {
+			return nil, errors.Errorf("context auth is invalid. err=%v", err)
+		}
+	}
+
+	return newClientFor(rc.Hosts, rc.Scheme, rc.TLS, creds, auth)
+}
+
+// saveConfig writes the configuration back to configPath.
+// The file can contain passwords so it is only readable by its owner.
+func saveConfig() error {
+	const op = "saveConfig"
+
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return errors.E(errors.Invalid, op, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0700); err != nil {
+		return errors.E(errors.IO, op, err)
+	}
+	if err := ioutil.WriteFile(configPath, data, 0600); err != nil {
+		return errors.E(errors.IO, op, err)
+	}
+
+	return nil
+}
+
+// selectedContext returns the name of the context in use.
+func selectedContext() string {
+	if *flContext != "" {
+		return *flContext
+	}
+	if name := os.Getenv("REAPER_CONTEXT"); name != "" {
+		return name
+	}
+	return config.CurrentContext
+}
+
+func listContexts(args []string) error {
+	var fs = flag.NewFlagSet("list-contexts", flag.ContinueOnError)
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	names := make([]string, 0, len(config.Contexts))
+	for name := range config.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if flOutput != OutputText {
+		return writeOutput(os.Stdout, flOutput, names)
+	}
+
+	current := selectedContext()
+	for _, name := range names {
+		marker := " "
+		if name == current {
+			marker = "*"
+		}
+		fmt.Printf("%s %s\n", marker, name)
+	}
+
+	return nil
+}
+
+func viewContext(args []string) error {
+	var fs = flag.NewFlagSet("view-context", flag.ContinueOnError)
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	name := fs.Arg(0)
+	if name == "" {
+		name = selectedContext()
+	}
+	if name == "" {
+		return errors.Str("please provide a context name")
+	}
+
+	rc, ok := config.Contexts[name]
+	if !ok {
+		return errors.Errorf("context %q not found in %s", name, configPath)
+	}
+
+	if rc.Credentials.Password != "" {
+		rc.Credentials.Password = "********"
+	}
+
+	if flOutput != OutputText {
+		return writeOutput(os.Stdout, flOutput, rc)
+	}
+
+	fmt.Printf("Context %q:\n\n", name)
+
+	return writeOutput(os.Stdout, OutputYAML, rc)
+}
+
+func useContext(args []string) error {
+	var fs = flag.NewFlagSet("use-context", flag.ContinueOnError)
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if fs.NArg() < 1 {
+		return errors.Str("please provide a context name")
+	}
+
+	name := fs.Arg(0)
+	if _, ok := config.Contexts[name]; !ok {
+		return errors.Errorf("context %q not found in %s", name, configPath)
+	}
+
+	config.CurrentContext = name
+	if err := saveConfig(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Switched to context %q\n", name)
+
+	return nil
+}
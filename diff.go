@@ -0,0 +1,247 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/fatih/color"
+	"github.com/vrischmann/flagutil"
+	"github.com/vrischmann/happyreaper/errors"
+	"github.com/vrischmann/happyreaper/reaper"
+)
+
+// diffFields are the fields compared by diff-schedules.
+var diffFields = []string{"intensity", "incremental_repair", "repair_parallelism", "scheduled_days_between", "segment_count", "segment_count_per_node"}
+
+// diffSide is one of the two sets of schedules compared by diff-schedules.
+type diffSide struct {
+	context *string
+	hosts   flagutil.NetworkAddresses
+	cluster *string
+}
+
+func newDiffSide(fs *flag.FlagSet, prefix, which string) *diffSide {
+	s := &diffSide{
+		context: fs.String(prefix+"-context", "", "The context of the "+which+" schedules (default the current Reaper)"),
+		cluster: fs.String(prefix+"-cluster", "", "The cluster of the "+which+" schedules (default all the clusters)"),
+	}
+
+	fs.Var(&s.hosts, prefix+"-host", "The Reaper hosts of the "+which+" schedules (comma separated list), using the connection flags")
+
+	return s
+}
+
+// String describes the side for the report.
+func (s *diffSide) String() string {
+	res := "current Reaper"
+	switch {
+	case *s.context != "":
+		res = "context " + *s.context
+	case len(s.hosts) > 0:
+		res = "host " + s.hosts.String()
+	}
+	if *s.cluster != "" {
+		res += ", cluster " + *s.cluster
+	}
+	return res
+}
+
+func (s *diffSide) list() ([]reaper.RepairSchedule, error) {
+	var (
+		c   = client
+		err error
+	)
+	switch {
+	case *s.context != "" && len(s.hosts) > 0:
+		return nil, errors.Str("please provide either a context or hosts")
+	case *s.context != "":
+		c, err = contextClient(*s.context)
+	case len(s.hosts) > 0:
+		c, err = newClientFor(s.hosts, *flScheme, flTLS, flCreds, flAuth)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.ListSchedules(ctx, *s.cluster, "")
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := make([]reaper.RepairSchedule, 0, len(res))
+	for _, sched := range res {
+		if sched.State != reaper.SDeleted {
+			schedules = append(schedules, sched)
+		}
+	}
+
+	return schedules, nil
+}
+
+const (
+	diffMissing   = "missing"
+	diffExtra     = "extra"
+	diffDiffers   = "differs"
+	diffDuplicate = "duplicate"
+)
+
+// scheduleDiff is a schedule missing in one of the two sides or differing between them.
+// A duplicate is a schedule with the same key as another one of its side, only the first one is compared.
+type scheduleDiff struct {
+	Status  string        `json:"status" yaml:"status"`
+	Key     string        `json:"key" yaml:"key"`
+	Cluster string        `json:"cluster_name,omitempty" yaml:"cluster_name,omitempty"`
+	IDA     string        `json:"id_a,omitempty" yaml:"id_a,omitempty"`
+	IDB     string        `json:"id_b,omitempty" yaml:"id_b,omitempty"`
+	Changes []fieldChange `json:"changes,omitempty" yaml:"changes,omitempty"`
+}
+
+// compareSchedules matches the schedules of a and b on key and returns how b differs from a.
+func compareSchedules(a, b []reaper.RepairSchedule, key func(reaper.RepairSchedule) string) []scheduleDiff {
+	var res []scheduleDiff
+
+	byKey := func(schedules []reaper.RepairSchedule, sideA bool) map[string]reaper.RepairSchedule {
+		m := make(map[string]reaper.RepairSchedule, len(schedules))
+		for _, sched := range schedules {
+			k := key(sched)
+			if _, ok := m[k]; !ok {
+				m[k] = sched
+				continue
+			}
+
+			d := scheduleDiff{Status: diffDuplicate, Key: k, Cluster: sched.ClusterName}
+			if sideA {
+				d.IDA = sched.ID
+			} else {
+				d.IDB = sched.ID
+			}
+			res = append(res, d)
+		}
+		return m
+	}
+
+	ma, mb := byKey(a, true), byKey(b, false)
+
+	for k, sa := range ma {
+		sb, ok := mb[k]
+		if !ok {
+			res = append(res, scheduleDiff{Status: diffMissing, Key: k, IDA: sa.ID})
+			continue
+		}
+		ca, cb := normalizeSegments(sa, sb)
+		if changes := scheduleChanges(ca, cb, diffFields); len(changes) > 0 {
+			res = append(res, scheduleDiff{Status: diffDiffers, Key: k, IDA: sa.ID, IDB: sb.ID, Changes: changes})
+		}
+	}
+	for k, sb := range mb {
+		if _, ok := ma[k]; !ok {
+			res = append(res, scheduleDiff{Status: diffExtra, Key: k, IDB: sb.ID})
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+
+	return res
+}
+
+// matchSides returns the schedules of a and b to compare and the key matching them.
+// The cluster is only left out of the key when both sides are restricted to a cluster,
+// a side with every cluster is otherwise restricted to the cluster of the other side.
+func matchSides(a, b []reaper.RepairSchedule, clusterA, clusterB string) ([]reaper.RepairSchedule, []reaper.RepairSchedule, func(reaper.RepairSchedule) string) {
+	key := func(sched reaper.RepairSchedule) string {
+		return scheduleKey(sched.ClusterName, sched.KeyspaceName, sched.ColumnFamilies)
+	}
+
+	switch {
+	case clusterA != "" && clusterB != "":
+		key = func(sched reaper.RepairSchedule) string {
+			return sched.KeyspaceName + tablesSuffix(sched.ColumnFamilies)
+		}
+	case clusterA != "":
+		b = schedulesOfCluster(b, clusterA)
+	case clusterB != "":
+		a = schedulesOfCluster(a, clusterB)
+	}
+
+	return a, b, key
+}
+
+// schedulesOfCluster returns the schedules of cluster.
+func schedulesOfCluster(schedules []reaper.RepairSchedule, cluster string) []reaper.RepairSchedule {
+	var res []reaper.RepairSchedule
+	for _, sched := range schedules {
+		if sched.ClusterName == cluster {
+			res = append(res, sched)
+		}
+	}
+	return res
+}
+
+func printScheduleDiffs(w io.Writer, diffs []scheduleDiff, a, b *diffSide) {
+	fmt.Fprintf(w, "a: %s\nb: %s\n\n", a, b)
+
+	if len(diffs) == 0 {
+		fmt.Fprintln(w, "The schedules are the same.")
+		return
+	}
+
+	for _, d := range diffs {
+		switch d.Status {
+		case diffMissing:
+			color.New(color.FgRed).Fprintf(w, "- %s is missing in b (%s)\n", d.Key, d.IDA)
+		case diffExtra:
+			color.New(color.FgGreen).Fprintf(w, "+ %s is only in b (%s)\n", d.Key, d.IDB)
+		case diffDiffers:
+			color.New(color.FgYellow).Fprintf(w, "~ %s differs (%s, %s)\n", d.Key, d.IDA, d.IDB)
+			for _, fc := range d.Changes {
+				fmt.Fprintf(w, "    %-25s %s -> %s\n", fc.Field+":", fc.Before, fc.After)
+			}
+		case diffDuplicate:
+			side, id := "a", d.IDA
+			if id == "" {
+				side, id = "b", d.IDB
+			}
+			color.New(color.FgRed).Fprintf(w, "! %s is in %s more than once, schedule %s of cluster %s is not compared\n", d.Key, side, id, d.Cluster)
+		}
+	}
+}
+
+func diffSchedules(args []string) error {
+	var (
+		fs = flag.NewFlagSet("diff-schedules", flag.ContinueOnError)
+		a  = newDiffSide(fs, "a", "first")
+		b  = newDiffSide(fs, "b", "second")
+	)
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	sa, err := a.list()
+	if err != nil {
+		return err
+	}
+	sb, err := b.list()
+	if err != nil {
+		return err
+	}
+
+	sa, sb, key := matchSides(sa, sb, *a.cluster, *b.cluster)
+
+	diffs := compareSchedules(sa, sb, key)
+
+	if flOutput != OutputText {
+		return writeOutput(os.Stdout, flOutput, diffs)
+	}
+
+	printScheduleDiffs(os.Stdout, diffs, a, b)
+
+	return nil
+}
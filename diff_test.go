@@ -0,0 +1,142 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vrischmann/happyreaper/reaper"
+)
+
+func TestCompareSchedules(t *testing.T) {
+	fullKey := func(sched reaper.RepairSchedule) string {
+		return scheduleKey(sched.ClusterName, sched.KeyspaceName, sched.ColumnFamilies)
+	}
+	keyspaceKey := func(sched reaper.RepairSchedule) string {
+		return sched.KeyspaceName + tablesSuffix(sched.ColumnFamilies)
+	}
+
+	inCluster := func(sched reaper.RepairSchedule, cluster string) reaper.RepairSchedule {
+		sched.ClusterName = cluster
+		return sched
+	}
+
+	differs := testSchedule("b1", "ks1")
+	differs.Intensity = 0.9
+
+	perNode := testSchedule("b1", "ks1")
+	perNode.SegmentCount, perNode.SegmentCountPerNode = 0, 64
+
+	moreSegments := testSchedule("b1", "ks1")
+	moreSegments.SegmentCount = 400
+
+	testCases := []struct {
+		name string
+		a, b []reaper.RepairSchedule
+		key  func(reaper.RepairSchedule) string
+		exp  []scheduleDiff
+	}{
+		{
+			"same",
+			[]reaper.RepairSchedule{testSchedule("a1", "ks1")},
+			[]reaper.RepairSchedule{testSchedule("b1", "ks1")},
+			fullKey,
+			nil,
+		},
+		{
+			"missing and extra",
+			[]reaper.RepairSchedule{testSchedule("a1", "ks1")},
+			[]reaper.RepairSchedule{testSchedule("b2", "ks2")},
+			fullKey,
+			[]scheduleDiff{
+				{Status: diffMissing, Key: "c1/ks1", IDA: "a1"},
+				{Status: diffExtra, Key: "c1/ks2", IDB: "b2"},
+			},
+		},
+		{
+			"differs",
+			[]reaper.RepairSchedule{testSchedule("a1", "ks1")},
+			[]reaper.RepairSchedule{differs},
+			fullKey,
+			[]scheduleDiff{
+				{Status: diffDiffers, Key: "c1/ks1", IDA: "a1", IDB: "b1", Changes: []fieldChange{{Field: "intensity", Before: "0.500", After: "0.900"}}},
+			},
+		},
+		{
+			"segments per node on one side only",
+			[]reaper.RepairSchedule{testSchedule("a1", "ks1")},
+			[]reaper.RepairSchedule{perNode},
+			fullKey,
+			nil,
+		},
+		{
+			"segments differ",
+			[]reaper.RepairSchedule{testSchedule("a1", "ks1")},
+			[]reaper.RepairSchedule{moreSegments},
+			fullKey,
+			[]scheduleDiff{
+				{Status: diffDiffers, Key: "c1/ks1", IDA: "a1", IDB: "b1", Changes: []fieldChange{{Field: "segment_count", Before: "200", After: "400"}}},
+			},
+		},
+		{
+			"duplicates without the cluster",
+			[]reaper.RepairSchedule{testSchedule("a1", "ks1")},
+			[]reaper.RepairSchedule{testSchedule("b1", "ks1"), inCluster(testSchedule("b2", "ks1"), "c2")},
+			keyspaceKey,
+			[]scheduleDiff{
+				{Status: diffDuplicate, Key: "ks1", Cluster: "c2", IDB: "b2"},
+			},
+		},
+		{
+			"duplicates in a",
+			[]reaper.RepairSchedule{testSchedule("a1", "ks1"), inCluster(testSchedule("a2", "ks1"), "c2")},
+			nil,
+			keyspaceKey,
+			[]scheduleDiff{
+				{Status: diffDuplicate, Key: "ks1", Cluster: "c2", IDA: "a2"},
+				{Status: diffMissing, Key: "ks1", IDA: "a1"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := compareSchedules(tc.a, tc.b, tc.key)
+			if !reflect.DeepEqual(res, tc.exp) {
+				t.Errorf("got %+v, expected %+v", res, tc.exp)
+			}
+		})
+	}
+}
+
+func TestMatchSides(t *testing.T) {
+	inCluster := func(id, cluster string) reaper.RepairSchedule {
+		sched := testSchedule(id, "ks1")
+		sched.ClusterName = cluster
+		return sched
+	}
+
+	a := []reaper.RepairSchedule{inCluster("a1", "c1"), inCluster("a2", "c2")}
+	b := []reaper.RepairSchedule{inCluster("b1", "c1"), inCluster("b2", "c2")}
+
+	testCases := []struct {
+		name               string
+		a, b               []reaper.RepairSchedule
+		clusterA, clusterB string
+		exp                []scheduleDiff
+	}{
+		{"no cluster", a, b, "", "", nil},
+		{"cluster in a", a[:1], b, "c1", "", nil},
+		{"cluster in b", a, b[1:], "", "c2", nil},
+		{"cluster in b missing in a", a[:1], b[1:], "", "c2", []scheduleDiff{{Status: diffExtra, Key: "c2/ks1", IDB: "b2"}}},
+		{"both clusters", a[:1], b[1:], "c1", "c2", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sa, sb, key := matchSides(tc.a, tc.b, tc.clusterA, tc.clusterB)
+			if res := compareSchedules(sa, sb, key); !reflect.DeepEqual(res, tc.exp) {
+				t.Errorf("got %+v, expected %+v", res, tc.exp)
+			}
+		})
+	}
+}
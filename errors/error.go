@@ -1,6 +1,9 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 type Kind uint8
 
@@ -8,6 +11,9 @@ const (
 	Other Kind = iota
 	Invalid
 	IO
+	API
+	NotFound
+	Conflict
 )
 
 func (k Kind) String() string {
@@ -18,6 +24,12 @@ func (k Kind) String() string {
 		return "invalid operation"
 	case IO:
 		return "I/O error"
+	case API:
+		return "API error"
+	case NotFound:
+		return "not found"
+	case Conflict:
+		return "conflict"
 	default:
 		return "invalid kind"
 	}
@@ -41,6 +53,45 @@ func E(kind Kind, op string, err error) error {
 	}
 }
 
+// KindOf returns the kind of err.
+// If err is an *Error of kind Other, the kind of its underlying error is returned.
+func KindOf(err error) Kind {
+	e, ok := err.(*Error)
+	switch {
+	case !ok:
+		return Other
+	case e.Kind == Other:
+		return KindOf(e.Err)
+	default:
+		return e.Kind
+	}
+}
+
+// HTTPError is returned when Reaper answers with an unexpected status code.
+type HTTPError struct {
+	StatusCode int
+	Method     string
+	URL        string
+	// Message is the error message sent by Reaper.
+	Message string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s: %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// AsHTTPError returns the *HTTPError contained in err, if any.
+func AsHTTPError(err error) (*HTTPError, bool) {
+	switch e := err.(type) {
+	case *HTTPError:
+		return e, true
+	case *Error:
+		return AsHTTPError(e.Err)
+	default:
+		return nil, false
+	}
+}
+
 type errorString struct {
 	s string
 }
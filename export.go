@@ -0,0 +1,264 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/vrischmann/flagutil"
+	"github.com/vrischmann/happyreaper/errors"
+	"github.com/vrischmann/happyreaper/reaper"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// scheduleExportVersion is the version of the files written by export-schedules.
+// It must be increased when a change makes the older files unreadable.
+const scheduleExportVersion = 1
+
+// scheduleExport is the file written by export-schedules and read by import-schedules.
+type scheduleExport struct {
+	Version    int                     `json:"version" yaml:"version"`
+	ExportedAt time.Time               `json:"exported_at" yaml:"exported_at"`
+	Schedules  []reaper.RepairSchedule `json:"schedules" yaml:"schedules"`
+}
+
+func exportSchedules(args []string) error {
+	var (
+		fs        = flag.NewFlagSet("export-schedules", flag.ContinueOnError)
+		flCluster = fs.String("cluster", "", "Only export the schedules of this cluster")
+		flFile    = fs.String("f", "", "The file to write (default the standard output)")
+	)
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	res, err := callListSchedules(*flCluster, "")
+	if err != nil {
+		return err
+	}
+
+	export := scheduleExport{
+		Version:    scheduleExportVersion,
+		ExportedAt: time.Now().UTC(),
+	}
+	for _, sched := range res {
+		if sched.State != reaper.SDeleted {
+			export.Schedules = append(export.Schedules, sched)
+		}
+	}
+
+	// The file is YAML unless JSON is asked for.
+	format := flOutput
+	if format != OutputJSON {
+		format = OutputYAML
+	}
+
+	if *flFile == "" {
+		return writeOutput(os.Stdout, format, export)
+	}
+
+	f, err := os.OpenFile(*flFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.E(errors.IO, "exportSchedules", err)
+	}
+	if err := writeOutput(f, format, export); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return errors.E(errors.IO, "exportSchedules", err)
+	}
+
+	color.Yellow("%d schedules exported to %s", len(export.Schedules), *flFile)
+
+	return nil
+}
+
+func readScheduleExport(path string) (scheduleExport, error) {
+	const op = "readScheduleExport"
+
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return scheduleExport{}, errors.E(errors.IO, op, err)
+	}
+
+	var res scheduleExport
+	if err := yaml.Unmarshal(data, &res); err != nil {
+		return scheduleExport{}, errors.E(errors.Invalid, op, err)
+	}
+	if res.Version != scheduleExportVersion {
+		return scheduleExport{}, errors.E(errors.Invalid, op, errors.Errorf("unsupported version %d, expected %d", res.Version, scheduleExportVersion))
+	}
+
+	return res, nil
+}
+
+// parseClusterMap parses the old=new pairs of -cluster-map.
+func parseClusterMap(pairs []string) (map[string]string, error) {
+	res := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		i := strings.Index(pair, "=")
+		if i <= 0 || i == len(pair)-1 {
+			return nil, errors.Errorf("invalid cluster mapping %q, expected old=new", pair)
+		}
+		res[pair[:i]] = pair[i+1:]
+	}
+	return res, nil
+}
+
+// importSpec returns the spec recreating sched on cluster.
+// The next activation is kept if it is in the future, otherwise Reaper schedules the first repair itself.
+func importSpec(sched reaper.RepairSchedule, cluster string, now time.Time) (scheduleSpec, error) {
+	spec := scheduleSpec{
+		Cluster:     cluster,
+		Keyspace:    sched.KeyspaceName,
+		Tables:      sched.ColumnFamilies,
+		Owner:       sched.Owner,
+		Intensity:   sched.Intensity,
+		Incremental: sched.IncrementalRepair,
+		Parallelism: sched.RepairParallelism,
+		DaysBetween: sched.ScheduledDaysBetween,
+	}
+	if sched.NextActivation != nil && sched.NextActivation.After(now) {
+		spec.TriggerTime = sched.NextActivation.Format(time.RFC3339)
+	}
+
+	if err := spec.normalize(); err != nil {
+		return spec, err
+	}
+
+	// Newer Reaper versions report the number of segments per node instead of the total. When neither is known
+	// Reaper uses its own default, rather than the default of add-schedule filled by normalize.
+	spec.Segments, spec.SegmentsPerNode = sched.SegmentCount, sched.SegmentCountPerNode
+	if spec.Segments > 0 && spec.SegmentsPerNode > 0 {
+		spec.Segments = 0
+	}
+
+	return spec, nil
+}
+
+func importSchedules(args []string) error {
+	var (
+		fs           = flag.NewFlagSet("import-schedules", flag.ContinueOnError)
+		flFile       = fs.String("f", "", "The file written by export-schedules, - reads the standard input")
+		flClusterMap flagutil.Strings
+		flDryRun     = fs.Bool("dry-run", false, "Only print the schedules which would be created")
+		flYes        = fs.Bool("yes", false, "Don't ask for confirmation")
+	)
+
+	fs.Var(&flClusterMap, "cluster-map", "Rename the clusters (comma separated list of old=new)")
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if *flFile == "" {
+		return errors.Str("please provide a file")
+	}
+
+	clusterMap, err := parseClusterMap(flClusterMap)
+	if err != nil {
+		return err
+	}
+
+	export, err := readScheduleExport(*flFile)
+	if err != nil {
+		return err
+	}
+
+	// Fetch the existing schedules of every target cluster once.
+	existing := make(map[string]bool)
+	fetched := make(map[string]bool)
+
+	var (
+		now     = time.Now()
+		changes []scheduleChange
+	)
+	for _, sched := range export.Schedules {
+		cluster := sched.ClusterName
+		if to, ok := clusterMap[cluster]; ok {
+			cluster = to
+		}
+
+		if !fetched[cluster] {
+			res, err := callListSchedules(cluster, "")
+			if err != nil {
+				return err
+			}
+			for _, s := range res {
+				if s.State != reaper.SDeleted {
+					existing[scheduleKey(s.ClusterName, s.KeyspaceName, s.ColumnFamilies)] = true
+				}
+			}
+			fetched[cluster] = true
+		}
+
+		spec, err := importSpec(sched, cluster, now)
+		if err != nil {
+			return errors.E(errors.Invalid, "importSchedules", errors.Errorf("schedule %s: %s", sched.ID, err))
+		}
+		if existing[spec.key()] {
+			fmt.Fprintf(os.Stderr, "schedule %s skipped, it already exists\n", spec.key())
+			continue
+		}
+		existing[spec.key()] = true
+
+		changes = append(changes, scheduleChange{
+			Action:  actionCreate,
+			Key:     spec.key(),
+			Changes: scheduleChanges(reaper.RepairSchedule{}, spec.schedule(), specFields),
+			spec:    spec,
+			paused:  sched.State == reaper.SPaused,
+		})
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("Nothing to import")
+		return nil
+	}
+
+	printPlan(previewWriter(), changes)
+
+	if *flDryRun {
+		return nil
+	}
+
+	if !*flYes {
+		ok, err := confirm("Import these %d schedules?", len(changes))
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	results := make([]bulkResult, 0, len(changes))
+	for _, c := range changes {
+		res := bulkResult{ID: c.Key}
+		if err := c.apply(); err != nil {
+			res.Error = describeError(err)
+		}
+		results = append(results, res)
+	}
+
+	return reportBulk(results)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vrischmann/happyreaper/reaper"
+)
+
+func TestImportSpec(t *testing.T) {
+	future := testNow.Add(48 * time.Hour)
+	past := testNow.Add(-48 * time.Hour)
+
+	base := testSchedule("1", "ks1")
+	base.ColumnFamilies = []string{"t1"}
+
+	incremental := base
+	incremental.IncrementalRepair = true
+
+	perNode := base
+	perNode.SegmentCount, perNode.SegmentCountPerNode = 0, 64
+
+	unknownSegments := base
+	unknownSegments.SegmentCount = 0
+
+	upcoming := base
+	upcoming.NextActivation = &future
+
+	overdue := base
+	overdue.NextActivation = &past
+
+	expSpec := func(fn func(*scheduleSpec)) scheduleSpec {
+		spec := testSpec("ks1")
+		spec.Cluster = "c2"
+		spec.Tables = []string{"t1"}
+		if fn != nil {
+			fn(&spec)
+		}
+		return spec
+	}
+
+	testCases := []struct {
+		name  string
+		sched reaper.RepairSchedule
+		exp   scheduleSpec
+	}{
+		{"copy", base, expSpec(nil)},
+		{"incremental", incremental, expSpec(func(s *scheduleSpec) { s.Incremental = true })},
+		{"segments per node", perNode, expSpec(func(s *scheduleSpec) { s.Segments, s.SegmentsPerNode = 0, 64 })},
+		{"unknown segments", unknownSegments, expSpec(func(s *scheduleSpec) { s.Segments = 0 })},
+		{"next activation kept", upcoming, expSpec(func(s *scheduleSpec) { s.TriggerTime = future.Format(time.RFC3339) })},
+		{"next activation passed", overdue, expSpec(nil)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := importSpec(tc.sched, "c2", testNow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(res, tc.exp) {
+				t.Errorf("got %+v, expected %+v", res, tc.exp)
+			}
+		})
+	}
+}
+
+func TestParseClusterMap(t *testing.T) {
+	testCases := []struct {
+		pairs []string
+		exp   map[string]string
+		err   bool
+	}{
+		{nil, map[string]string{}, false},
+		{[]string{"old=new", "a=b=c"}, map[string]string{"old": "new", "a": "b=c"}, false},
+		{[]string{"old"}, nil, true},
+		{[]string{"=new"}, nil, true},
+		{[]string{"old="}, nil, true},
+	}
+
+	for _, tc := range testCases {
+		res, err := parseClusterMap(tc.pairs)
+		if (err != nil) != tc.err {
+			t.Errorf("parseClusterMap(%v): got error %v, expected error %v", tc.pairs, err, tc.err)
+			continue
+		}
+		if !tc.err && !reflect.DeepEqual(res, tc.exp) {
+			t.Errorf("parseClusterMap(%v): got %v, expected %v", tc.pairs, res, tc.exp)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/vrischmann/flagutil"
+	"github.com/vrischmann/happyreaper/reaper"
+)
+
+// repairFilter selects repair runs, it is shared by list-repairs and the bulk repair commands.
+type repairFilter struct {
+	runState    reaper.RunState
+	cluster     *string
+	keyspace    *string
+	tables      flagutil.Strings
+	owner       *string
+	cause       *string
+	startAfter  myTime
+	startBefore myTime
+}
+
+func newRepairFilter(fs *flag.FlagSet) *repairFilter {
+	f := &repairFilter{
+		cluster:  fs.String("cluster", "", "Filter by cluster"),
+		keyspace: fs.String("keyspace", "", "Filter by keyspace"),
+		owner:    fs.String("owner", "", "Filter by owner"),
+		cause:    fs.String("cause", "", "Filter by cause"),
+	}
+
+	fs.Var(&f.runState, "run-state", "Filter by run state")
+	fs.Var(&f.tables, "tables", "Filter by tables (comma separated list of tables)")
+	fs.Var(&f.startAfter, "start-after", "Filter by runs that start after this date")
+	fs.Var(&f.startBefore, "start-before", "Filter by runs that start before this date")
+
+	return f
+}
+
+// isZero returns true if no filter is set.
+func (f *repairFilter) isZero() bool {
+	return f.runState == "" && *f.cluster == "" && *f.keyspace == "" && len(f.tables) == 0 &&
+		*f.owner == "" && *f.cause == "" && f.startAfter.IsZero() && f.startBefore.IsZero()
+}
+
+func (f *repairFilter) match(run reaper.RepairRun) bool {
+	switch {
+	case f.runState != "" && f.runState != run.State:
+		return false
+
+	case *f.cluster != "" && *f.cluster != run.ClusterName:
+		return false
+
+	case *f.keyspace != "" && *f.keyspace != run.KeyspaceName:
+		return false
+
+	case len(f.tables) > 0 && !contains(run.ColumnFamilies, f.tables):
+		return false
+
+	case *f.owner != "" && *f.owner != run.Owner:
+		return false
+
+	case *f.cause != "" && *f.cause != run.Cause:
+		return false
+
+	case (!f.startAfter.IsZero() || !f.startBefore.IsZero()) && run.StartTime == nil:
+		return false
+
+	case !f.startAfter.IsZero() && run.StartTime.Before(f.startAfter.Time):
+		return false
+
+	case !f.startBefore.IsZero() && run.StartTime.After(f.startBefore.Time):
+		return false
+	}
+
+	return true
+}
+
+// list returns the repair runs matching the filter.
+func (f *repairFilter) list() ([]reaper.RepairRun, error) {
+	res, err := client.ListRepairRuns(ctx, f.runState)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]reaper.RepairRun, 0, len(res))
+	for _, run := range res {
+		if f.match(run) {
+			runs = append(runs, run)
+		}
+	}
+
+	return runs, nil
+}
+
+// scheduleFilter selects repair schedules, it is shared by list-schedules and the bulk schedule commands.
+type scheduleFilter struct {
+	state    reaper.ScheduleState
+	cluster  *string
+	keyspace *string
+	tables   flagutil.Strings
+	owner    *string
+}
+
+func newScheduleFilter(fs *flag.FlagSet) *scheduleFilter {
+	f := &scheduleFilter{
+		cluster:  fs.String("cluster", "", "Filter by cluster"),
+		keyspace: fs.String("keyspace", "", "Filter by keyspace"),
+		owner:    fs.String("owner", "", "Filter by owner"),
+	}
+
+	fs.Var(&f.state, "state", "Filter by state")
+	fs.Var(&f.tables, "tables", "Filter by tables (comma separated list of tables)")
+
+	return f
+}
+
+// isZero returns true if no filter is set.
+func (f *scheduleFilter) isZero() bool {
+	return f.state == "" && *f.cluster == "" && *f.keyspace == "" && len(f.tables) == 0 && *f.owner == ""
+}
+
+func (f *scheduleFilter) match(sched reaper.RepairSchedule) bool {
+	switch {
+	case f.state != "" && f.state != sched.State:
+		return false
+
+	case *f.cluster != "" && *f.cluster != sched.ClusterName:
+		return false
+
+	case *f.keyspace != "" && *f.keyspace != sched.KeyspaceName:
+		return false
+
+	case len(f.tables) > 0 && !contains(sched.ColumnFamilies, f.tables):
+		return false
+
+	case *f.owner != "" && *f.owner != sched.Owner:
+		return false
+	}
+
+	return true
+}
+
+// list returns the repair schedules matching the filter.
+func (f *scheduleFilter) list() ([]reaper.RepairSchedule, error) {
+	res, err := callListSchedules(*f.cluster, *f.keyspace)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := make([]reaper.RepairSchedule, 0, len(res))
+	for _, sched := range res {
+		if f.match(sched) {
+			schedules = append(schedules, sched)
+		}
+	}
+
+	return schedules, nil
+}
@@ -1,43 +1,20 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/vrischmann/flagutil"
 	"github.com/vrischmann/happyreaper/errors"
-)
-
-func makeURL(path string) string {
-	return "http://" + flReaperHost[0] + path
-}
-
-type Parallelism string
-
-func (p Parallelism) String() string { return string(p) }
-
-func (p *Parallelism) Set(s string) error {
-	switch {
-	case strings.EqualFold(s, "sequential"):
-		*p = Sequential
-	case strings.EqualFold(s, "parallel"):
-		*p = Parallel
-	case strings.EqualFold(s, "datacenter_aware"):
-		*p = DatacenterAware
-	default:
-		return errors.Errorf("invalid parallelism %q", s)
-	}
-	return nil
-}
-
-const (
-	Sequential      Parallelism = "SEQUENTIAL"
-	Parallel        Parallelism = "PARALLEL"
-	DatacenterAware Parallelism = "DATACENTER_AWARE"
+	"github.com/vrischmann/happyreaper/reaper"
 )
 
 func contains(a, b []string) bool {
@@ -79,6 +56,21 @@ func (t myTime) String() string {
 var (
 	mainFs       = flag.NewFlagSet("main", flag.ContinueOnError)
 	flReaperHost flagutil.NetworkAddresses
+	flScheme     = mainFs.String("scheme", "", "The scheme to use, http or https (default http, https if a TLS flag is set)")
+	flTLS        reaper.TLSOptions
+	flCreds      reaper.Credentials
+	flCredsFile  = mainFs.String("credentials-file", "", "A YAML file containing the username and password")
+	flAuth       reaper.AuthMethod
+	flOutput     = OutputText
+	flVerbose    = mainFs.Bool("verbose", false, "Verbose mode, print which host served each request")
+	flTimeout    = mainFs.Duration("timeout", 30*time.Second, "The timeout of each request, 0 means no timeout")
+	flRetries    = mainFs.Int("retries", 2, "The number of retries of the GET and PUT requests when all hosts failed")
+	flConfig     = mainFs.String("config", "", "The configuration file (default ~/.config/happyreaper/config.yaml)")
+	flContext    = mainFs.String("context", "", "The context of the configuration file to use (default the current context)")
+
+	client *reaper.Client
+	// ctx is cancelled on SIGINT or SIGTERM, every command must use it.
+	ctx context.Context
 )
 
 func printMainUsage(name string, fs *flag.FlagSet) {
@@ -88,33 +80,131 @@ func printMainUsage(name string, fs *flag.FlagSet) {
 }
 
 func init() {
-	mainFs.Var(&flReaperHost, "host", "The reaper host")
+	mainFs.Var(&flReaperHost, "host", "The reaper hosts (comma separated list), tried in order if one fails")
+	mainFs.StringVar(&flTLS.CAFile, "ca-file", "", "The PEM bundle of CAs used to verify the reaper certificate")
+	mainFs.StringVar(&flTLS.CertFile, "cert-file", "", "The client certificate")
+	mainFs.StringVar(&flTLS.KeyFile, "key-file", "", "The client key")
+	mainFs.BoolVar(&flTLS.InsecureSkipVerify, "insecure-skip-verify", false, "Don't verify the reaper certificate")
+	mainFs.StringVar(&flCreds.Username, "username", "", "The username if reaper has authentication enabled")
+	mainFs.StringVar(&flCreds.Password, "password", "", "The password if reaper has authentication enabled")
+	mainFs.Var(&flAuth, "auth", "The authentication method: login or basic (default login)")
+	mainFs.Var(&flOutput, "output", "The output format: text, json, yaml or csv")
 }
 
 type commandFn func([]string) error
 
+// exitStatusError is returned by a command which needs main to exit with a specific status code.
+// A nil err means the command already reported the problem and main exits without logging anything.
+type exitStatusError struct {
+	code int
+	err  error
+}
+
+func (e *exitStatusError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.err.Error()
+}
+
+// Exit status codes by kind of error, errors of kind Other exit with 1.
+// They don't overlap with the exit status codes of watch-repair.
+var kindExitCodes = map[errors.Kind]int{
+	errors.Invalid:  10,
+	errors.IO:       11,
+	errors.API:      12,
+	errors.NotFound: 13,
+	errors.Conflict: 14,
+}
+
+func exitCode(err error) int {
+	if code, ok := kindExitCodes[errors.KindOf(err)]; ok {
+		return code
+	}
+	return 1
+}
+
+// describeError explains the errors returned by Reaper in a friendlier way than the raw error.
+func describeError(err error) string {
+	he, ok := errors.AsHTTPError(err)
+	if !ok {
+		return err.Error()
+	}
+
+	var msg string
+	switch he.StatusCode {
+	case http.StatusBadRequest:
+		msg = "reaper rejected the request as invalid"
+	case http.StatusUnauthorized, http.StatusForbidden:
+		msg = "reaper denied access, check the credentials"
+	case http.StatusNotFound:
+		msg = "not found"
+	case http.StatusConflict:
+		msg = "conflict, the operation is not possible in the current state"
+	default:
+		return he.Error()
+	}
+
+	if he.Message != "" {
+		msg += ": " + he.Message
+	}
+
+	return fmt.Sprintf("%s (%s %s)", msg, he.Method, he.URL)
+}
+
 var commands = map[string]map[string]commandFn{
+	"context": {
+		"list-contexts": listContexts,
+		"view-context":  viewContext,
+		"use-context":   useContext,
+	},
 	"cluster": {
-		"add-cluster":   addCluster,
-		"view-cluster":  viewCluster,
-		"list-clusters": listClusters,
+		"add-cluster":          addCluster,
+		"view-cluster":         viewCluster,
+		"list-clusters":        listClusters,
+		"update-cluster-seeds": updateClusterSeeds,
+		"delete-cluster":       deleteCluster,
 	},
 	"repair": {
-		"add-repair":    addRepair,
-		"view-repair":   viewRepair,
-		"list-repairs":  listRepairs,
-		"pause-repair":  pauseRepair,
-		"resume-repair": resumeRepair,
-		"delete-repair": deleteRepair,
+		"add-repair":      addRepair,
+		"view-repair":     viewRepair,
+		"update-repair":   updateRepair,
+		"watch-repair":    watchRepair,
+		"list-repairs":    listRepairs,
+		"list-segments":   listSegments,
+		"abort-segment":   abortSegment,
+		"reset-segment":   resetSegment,
+		"stalled-repairs": stalledRepairs,
+		"pause-repair":    pauseRepair,
+		"resume-repair":   resumeRepair,
+		"delete-repair":   deleteRepair,
+	},
+	"maintenance": {
+		"maintenance-start": maintenanceStart,
+		"maintenance-end":   maintenanceEnd,
+	},
+	"ui": {
+		"ui": runUI,
+	},
+	"metrics": {
+		"serve-metrics": serveMetrics,
+		"check":         check,
 	},
 	"schedule": {
-		"add-schedule":    addSchedule,
-		"view-schedule":   viewSchedule,
-		"list-schedules":  listSchedules,
-		"pause-schedule":  pauseSchedule,
-		"resume-schedule": resumeSchedule,
-		"delete-schedule": deleteSchedule,
-		"next-schedule":   nextSchedule,
+		"add-schedule":      addSchedule,
+		"view-schedule":     viewSchedule,
+		"list-schedules":    listSchedules,
+		"update-schedule":   updateSchedule,
+		"pause-schedule":    pauseSchedule,
+		"resume-schedule":   resumeSchedule,
+		"delete-schedule":   deleteSchedule,
+		"next-schedule":     nextSchedule,
+		"apply":             applySchedules,
+		"export-schedules":  exportSchedules,
+		"import-schedules":  importSchedules,
+		"diff-schedules":    diffSchedules,
+		"calendar":          calendar,
+		"stagger-schedules": staggerSchedules,
 	},
 }
 
@@ -137,6 +227,45 @@ func printUsage() {
 	}
 }
 
+// commandArg returns the command following the global flags in args, even if the flags couldn't be parsed.
+// The unknown flags are assumed to have no value.
+func commandArg(args []string) string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+			return ""
+		case arg == "-" || !strings.HasPrefix(arg, "-"):
+			return arg
+		case strings.Contains(arg, "="):
+			continue
+		}
+
+		f := mainFs.Lookup(strings.TrimLeft(arg, "-"))
+		if f == nil {
+			continue
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			continue
+		}
+		// Skip the value of the flag.
+		i++
+	}
+
+	return ""
+}
+
+// fatal reports an error which happened before command could run and exits.
+func fatal(command string, err error) {
+	if command == "check" {
+		exitCheckSetup(err)
+	}
+	log.Fatal(err)
+}
+
 func main() {
 	err := mainFs.Parse(os.Args[1:])
 	switch {
@@ -144,24 +273,14 @@ func main() {
 		printUsage()
 		return
 	case err != nil:
-		log.Fatal(err)
-		return
+		fatal(commandArg(os.Args[1:]), err)
 	}
 
-	if len(flReaperHost) == 0 {
-		val := os.Getenv("REAPER_HOST")
-		if val != "" {
-			if err := flReaperHost.Set(val); err != nil {
-				log.Fatalf("REAPER_HOST value is invalid. err=%v", err)
-			}
-		}
+	if err := loadConfig(); err != nil {
+		fatal(mainFs.Arg(0), err)
 	}
-
-	if len(flReaperHost) == 0 {
-		log.Println("please provide a reaper host")
-		flag.PrintDefaults()
-		printUsage()
-		os.Exit(1)
+	if err := loadEnv(); err != nil {
+		fatal(mainFs.Arg(0), err)
 	}
 
 	if mainFs.NArg() < 1 {
@@ -171,6 +290,10 @@ func main() {
 		os.Exit(1)
 	}
 
+	var stop context.CancelFunc
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	command := mainFs.Arg(0)
 	fn := findCommand(command)
 
@@ -178,7 +301,34 @@ func main() {
 		log.Fatalf("invalid command %q", command)
 	}
 
-	if err := fn(mainFs.Args()[1:]); err != nil {
-		log.Fatal(err)
+	// The context commands only work on the configuration file.
+	if _, ok := commands["context"][command]; !ok {
+		if len(flReaperHost) == 0 {
+			if command == "check" {
+				exitCheckSetup(errors.Str("please provide a reaper host"))
+			}
+			log.Println("please provide a reaper host")
+			flag.PrintDefaults()
+			printUsage()
+			os.Exit(1)
+		}
+
+		client, err = newClient()
+		if err != nil {
+			fatal(command, err)
+		}
+	}
+
+	err = fn(mainFs.Args()[1:])
+	switch e := err.(type) {
+	case nil:
+	case *exitStatusError:
+		if e.err != nil {
+			log.Print(e)
+		}
+		os.Exit(e.code)
+	default:
+		log.Print(describeError(err))
+		os.Exit(exitCode(err))
 	}
 }
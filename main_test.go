@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestCommandArg(t *testing.T) {
+	testCases := []struct {
+		args []string
+		exp  string
+	}{
+		{[]string{"check"}, "check"},
+		{[]string{"-host", "reaper:8080", "check", "-cluster", "c1"}, "check"},
+		{[]string{"-host=reaper:8080", "--timeout", "10s", "check"}, "check"},
+		{[]string{"-verbose", "check"}, "check"},
+		{[]string{"-host", "check", "list-runs"}, "list-runs"},
+		{[]string{"-timeout", "bogus", "check"}, "check"},
+		{[]string{"-unknown", "check"}, "check"},
+		{[]string{"--", "check"}, "check"},
+		{[]string{"-host", "reaper:8080"}, ""},
+		{nil, ""},
+	}
+
+	for _, tc := range testCases {
+		if got := commandArg(tc.args); got != tc.exp {
+			t.Errorf("commandArg(%q): got %q, expected %q", tc.args, got, tc.exp)
+		}
+	}
+}
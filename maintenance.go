@@ -0,0 +1,330 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/vrischmann/happyreaper/errors"
+	"github.com/vrischmann/happyreaper/reaper"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// maintenanceState records what maintenance-start paused so that maintenance-end resumes exactly that.
+type maintenanceState struct {
+	Cluster   string    `yaml:"cluster"`
+	StartTime time.Time `yaml:"start_time"`
+	Repairs   []string  `yaml:"repairs"`
+	Schedules []string  `yaml:"schedules"`
+}
+
+// nextToConfig returns the path of the file name in the directory of the configuration file.
+func nextToConfig(name string) string {
+	if configPath == "" {
+		return name
+	}
+	return filepath.Join(filepath.Dir(configPath), name)
+}
+
+// defaultMaintenanceFile returns the state file of cluster, next to the configuration file.
+// The characters of the cluster name which could make the path point elsewhere are replaced.
+func defaultMaintenanceFile(cluster string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, cluster)
+
+	return nextToConfig("maintenance-" + name + ".yaml")
+}
+
+func readMaintenanceState(path string) (maintenanceState, error) {
+	const op = "readMaintenanceState"
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return maintenanceState{}, errors.E(errors.IO, op, err)
+	}
+
+	var res maintenanceState
+	if err := yaml.Unmarshal(data, &res); err != nil {
+		return maintenanceState{}, errors.E(errors.Invalid, op, err)
+	}
+
+	return res, nil
+}
+
+func writeMaintenanceState(path string, state maintenanceState) error {
+	const op = "writeMaintenanceState"
+
+	data, err := yaml.Marshal(state)
+	if err != nil {
+		return errors.E(errors.Invalid, op, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.E(errors.IO, op, err)
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return errors.E(errors.IO, op, err)
+	}
+
+	return nil
+}
+
+// succeeded returns the IDs of the successful operations.
+func succeeded(results []bulkResult) []string {
+	var res []string
+	for _, r := range results {
+		if r.Error == "" {
+			res = append(res, r.ID)
+		}
+	}
+	return res
+}
+
+// failed returns the IDs of the failed operations.
+func failed(results []bulkResult) []string {
+	var res []string
+	for _, r := range results {
+		if r.Error != "" {
+			res = append(res, r.ID)
+		}
+	}
+	return res
+}
+
+func maintenanceStart(args []string) error {
+	var (
+		fs     = flag.NewFlagSet("maintenance-start", flag.ContinueOnError)
+		flFile = fs.String("file", "", "The state file (default maintenance-<cluster>.yaml next to the configuration file)")
+		opts   = newBulkOptions(fs)
+	)
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if fs.NArg() < 1 {
+		return errors.Str("please provide a cluster name")
+	}
+
+	flName := fs.Arg(0)
+	if *flFile == "" {
+		*flFile = defaultMaintenanceFile(flName)
+	}
+
+	if _, err := os.Stat(*flFile); err == nil {
+		return errors.E(errors.Conflict, "maintenanceStart", errors.Errorf("%s exists, a maintenance is already in progress. Run maintenance-end first", *flFile))
+	}
+
+	cl, err := client.GetCluster(ctx, flName)
+	if err != nil {
+		return err
+	}
+
+	var (
+		runs      []reaper.RepairRun
+		runIDs    []string
+		schedules []reaper.RepairSchedule
+		schedIDs  []string
+	)
+	for _, run := range cl.RepairRuns {
+		if run.State == reaper.Running {
+			runs = append(runs, run)
+			runIDs = append(runIDs, run.ID)
+		}
+	}
+	for _, sc := range cl.RepairSchedules {
+		if sc.State == reaper.SActive {
+			schedules = append(schedules, sc)
+			schedIDs = append(schedIDs, sc.ID)
+		}
+	}
+
+	if len(runs) == 0 && len(schedules) == 0 {
+		fmt.Printf("Nothing to pause on cluster %s\n", flName)
+		return nil
+	}
+
+	if len(runs) > 0 {
+		if err := printRunsTable(previewWriter(), runs, nil, true); err != nil {
+			return err
+		}
+	}
+	if len(schedules) > 0 {
+		if err := printSchedulesTable(previewWriter(), schedules, nil, true); err != nil {
+			return err
+		}
+	}
+
+	if !*opts.yes {
+		ok, err := confirm("Pause %d repairs and %d schedules of cluster %s?", len(runs), len(schedules), flName)
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	// Record everything before pausing anything, so that maintenance-end knows what to resume even if
+	// this is interrupted. It only resumes what is actually PAUSED.
+	state := maintenanceState{
+		Cluster:   flName,
+		StartTime: time.Now(),
+		Repairs:   runIDs,
+		Schedules: schedIDs,
+	}
+	if err := writeMaintenanceState(*flFile, state); err != nil {
+		return err
+	}
+
+	runResults := runBulk(runIDs, *opts.concurrency, func(id string) error {
+		_, err := client.ChangeRepairRunState(ctx, id, reaper.Paused)
+		return err
+	})
+
+	// When interrupted, a request reported as failed might have been served, keep all the IDs then.
+	if ctx.Err() != nil {
+		return reportBulk(runResults)
+	}
+
+	state.Repairs = succeeded(runResults)
+	if err := writeMaintenanceState(*flFile, state); err != nil {
+		return err
+	}
+
+	schedResults := runBulk(schedIDs, *opts.concurrency, func(id string) error {
+		_, err := client.ChangeScheduleState(ctx, id, reaper.SPaused)
+		return err
+	})
+
+	if ctx.Err() != nil {
+		return reportBulk(append(runResults, schedResults...))
+	}
+
+	state.Schedules = succeeded(schedResults)
+	if err := writeMaintenanceState(*flFile, state); err != nil {
+		return err
+	}
+
+	err = reportBulk(append(runResults, schedResults...))
+
+	color.Yellow("Maintenance of cluster %s started, the paused repairs and schedules are recorded in %s", flName, *flFile)
+
+	return err
+}
+
+func maintenanceEnd(args []string) error {
+	var (
+		fs     = flag.NewFlagSet("maintenance-end", flag.ContinueOnError)
+		flFile = fs.String("file", "", "The state file (default maintenance-<cluster>.yaml next to the configuration file)")
+		opts   = newBulkOptions(fs)
+	)
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if fs.NArg() < 1 {
+		return errors.Str("please provide a cluster name")
+	}
+
+	flName := fs.Arg(0)
+	if *flFile == "" {
+		*flFile = defaultMaintenanceFile(flName)
+	}
+
+	state, err := readMaintenanceState(*flFile)
+	if err != nil {
+		return err
+	}
+	if state.Cluster != flName {
+		return errors.Errorf("%s is the state file of cluster %s", *flFile, state.Cluster)
+	}
+
+	cl, err := client.GetCluster(ctx, flName)
+	if err != nil {
+		return err
+	}
+
+	runStates := make(map[string]reaper.RunState)
+	for _, run := range cl.RepairRuns {
+		runStates[run.ID] = run.State
+	}
+	schedStates := make(map[string]reaper.ScheduleState)
+	for _, sc := range cl.RepairSchedules {
+		schedStates[sc.ID] = sc.State
+	}
+
+	// Only resume what is still paused, anything else was deleted or changed by someone else since.
+	var runIDs, schedIDs []string
+	for _, id := range state.Repairs {
+		switch st, ok := runStates[id]; {
+		case !ok || st == reaper.Deleted:
+			color.Yellow("Repair %s skipped, it was deleted", id)
+		case st != reaper.Paused:
+			color.Yellow("Repair %s skipped, it is %s", id, st)
+		default:
+			runIDs = append(runIDs, id)
+		}
+	}
+	for _, id := range state.Schedules {
+		switch st, ok := schedStates[id]; {
+		case !ok || st == reaper.SDeleted:
+			color.Yellow("Schedule %s skipped, it was deleted", id)
+		case st != reaper.SPaused:
+			color.Yellow("Schedule %s skipped, it is %s", id, st)
+		default:
+			schedIDs = append(schedIDs, id)
+		}
+	}
+
+	if (len(runIDs) > 0 || len(schedIDs) > 0) && !*opts.yes {
+		ok, err := confirm("Resume %d repairs and %d schedules of cluster %s?", len(runIDs), len(schedIDs), flName)
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	runResults := runBulk(runIDs, *opts.concurrency, func(id string) error {
+		_, err := client.ChangeRepairRunState(ctx, id, reaper.Running)
+		return err
+	})
+	schedResults := runBulk(schedIDs, *opts.concurrency, func(id string) error {
+		_, err := client.ChangeScheduleState(ctx, id, reaper.SActive)
+		return err
+	})
+
+	if err := reportBulk(append(runResults, schedResults...)); err != nil {
+		// Keep only what failed in the state file so that maintenance-end can be run again.
+		state.Repairs = failed(runResults)
+		state.Schedules = failed(schedResults)
+		if werr := writeMaintenanceState(*flFile, state); werr != nil {
+			return werr
+		}
+		return err
+	}
+
+	if err := os.Remove(*flFile); err != nil {
+		return errors.E(errors.IO, "maintenanceEnd", err)
+	}
+
+	fmt.Printf("Maintenance of cluster %s ended\n", flName)
+
+	return nil
+}
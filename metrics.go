@@ -0,0 +1,254 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/vrischmann/flagutil"
+	"github.com/vrischmann/happyreaper/errors"
+	"github.com/vrischmann/happyreaper/reaper"
+)
+
+var (
+	descUp = prometheus.NewDesc("reaper_up",
+		"Whether the last poll of the Reaper API succeeded.", nil, nil)
+	descLastPoll = prometheus.NewDesc("reaper_last_poll_timestamp_seconds",
+		"The time of the last successful poll of the Reaper API.", nil, nil)
+	descRuns = prometheus.NewDesc("reaper_repair_runs",
+		"The number of repair runs by state.", []string{"cluster", "keyspace", "state"}, nil)
+	descErrorRuns = prometheus.NewDesc("reaper_repair_runs_error",
+		"The number of repair runs in the ERROR state.", []string{"cluster", "keyspace"}, nil)
+	descSegments = prometheus.NewDesc("reaper_repair_run_segments",
+		"The number of segments of the repair runs not finished yet.", []string{"cluster", "keyspace", "id", "state"}, nil)
+	descSegmentsRepaired = prometheus.NewDesc("reaper_repair_run_segments_repaired",
+		"The number of segments repaired of the repair runs not finished yet.", []string{"cluster", "keyspace", "id", "state"}, nil)
+	descSinceLastActivation = prometheus.NewDesc("reaper_repair_schedule_seconds_since_last_activation",
+		"The time since the last repair run created by the schedule.", []string{"cluster", "keyspace", "id", "state"}, nil)
+	descUntilNextActivation = prometheus.NewDesc("reaper_repair_schedule_seconds_until_next_activation",
+		"The time until the next activation of the schedule, negative if it is overdue.", []string{"cluster", "keyspace", "id", "state"}, nil)
+)
+
+// metricsSnapshot is the result of one poll of the Reaper API.
+type metricsSnapshot struct {
+	up       bool
+	lastPoll time.Time
+	clusters []reaper.Cluster
+}
+
+// metricsCollector exposes the last snapshot taken by poll.
+//
+// The durations are computed when scraped, not when polled, so that they don't depend on the poll interval.
+type metricsCollector struct {
+	clusters []string
+
+	mu       sync.Mutex
+	snapshot metricsSnapshot
+}
+
+func (c *metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descUp
+	ch <- descLastPoll
+	ch <- descRuns
+	ch <- descErrorRuns
+	ch <- descSegments
+	ch <- descSegmentsRepaired
+	ch <- descSinceLastActivation
+	ch <- descUntilNextActivation
+}
+
+// poll fetches the clusters and their repair runs and schedules.
+// When it fails the previous snapshot is kept and only reaper_up changes.
+func (c *metricsCollector) poll() error {
+	names := c.clusters
+	if len(names) == 0 {
+		var err error
+		names, err = client.ListClusters(ctx)
+		if err != nil {
+			c.setUp(false)
+			return err
+		}
+	}
+
+	clusters := make([]reaper.Cluster, 0, len(names))
+	for _, name := range names {
+		cl, err := client.GetCluster(ctx, name)
+		if err != nil {
+			c.setUp(false)
+			return err
+		}
+		clusters = append(clusters, cl)
+	}
+
+	c.mu.Lock()
+	c.snapshot = metricsSnapshot{
+		up:       true,
+		lastPoll: time.Now(),
+		clusters: clusters,
+	}
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *metricsCollector) setUp(up bool) {
+	c.mu.Lock()
+	c.snapshot.up = up
+	c.mu.Unlock()
+}
+
+// isScheduledBy returns true if run was created by the schedule identified by id.
+// Reaper records it in the cause of the run, "scheduled run (schedule id <id>)".
+func isScheduledBy(run reaper.RepairRun, id string) bool {
+	// Match the whole ID, schedule 1 must not match the runs of schedule 12.
+	return strings.Contains(run.Cause, "(schedule id "+id+")")
+}
+
+// lastActivation returns the creation time of the last repair run created by sched.
+func lastActivation(cl reaper.Cluster, sched reaper.RepairSchedule) *time.Time {
+	var res *time.Time
+	for _, run := range cl.RepairRuns {
+		if run.CreationTime == nil || !isScheduledBy(run, sched.ID) {
+			continue
+		}
+		if res == nil || run.CreationTime.After(*res) {
+			res = run.CreationTime
+		}
+	}
+	return res
+}
+
+func (c *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	snapshot := c.snapshot
+	c.mu.Unlock()
+
+	gauge := func(desc *prometheus.Desc, v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, labels...)
+	}
+	boolValue := func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+
+	gauge(descUp, boolValue(snapshot.up))
+	if snapshot.lastPoll.IsZero() {
+		return
+	}
+	gauge(descLastPoll, float64(snapshot.lastPoll.Unix()))
+
+	now := time.Now()
+
+	type runKey struct {
+		cluster, keyspace string
+		state             reaper.RunState
+	}
+
+	for _, cl := range snapshot.clusters {
+		counts := make(map[runKey]int)
+		errorCounts := make(map[string]int)
+
+		for _, run := range cl.RepairRuns {
+			counts[runKey{cl.Name, run.KeyspaceName, run.State}]++
+			if run.State == reaper.Error {
+				errorCounts[run.KeyspaceName]++
+			}
+
+			switch run.State {
+			case reaper.NotStarted, reaper.Running, reaper.Paused:
+				gauge(descSegments, float64(run.TotalSegments), cl.Name, run.KeyspaceName, run.ID, run.State.String())
+				gauge(descSegmentsRepaired, float64(run.SegmentsRepaired), cl.Name, run.KeyspaceName, run.ID, run.State.String())
+			}
+		}
+
+		for k, n := range counts {
+			gauge(descRuns, float64(n), k.cluster, k.keyspace, k.state.String())
+		}
+		for keyspace, n := range errorCounts {
+			gauge(descErrorRuns, float64(n), cl.Name, keyspace)
+		}
+
+		for _, sched := range cl.RepairSchedules {
+			if sched.State == reaper.SDeleted {
+				continue
+			}
+
+			labels := []string{cl.Name, sched.KeyspaceName, sched.ID, sched.State.String()}
+
+			if t := lastActivation(cl, sched); t != nil {
+				gauge(descSinceLastActivation, now.Sub(*t).Seconds(), labels...)
+			}
+			if sched.NextActivation != nil {
+				gauge(descUntilNextActivation, sched.NextActivation.Sub(now).Seconds(), labels...)
+			}
+		}
+	}
+}
+
+func serveMetrics(args []string) error {
+	var (
+		fs         = flag.NewFlagSet("serve-metrics", flag.ContinueOnError)
+		flListen   = fs.String("listen", ":9753", "The address to listen on")
+		flInterval = fs.Duration("interval", time.Minute, "The interval between two polls of the Reaper API")
+		flClusters flagutil.Strings
+	)
+
+	fs.Var(&flClusters, "clusters", "The clusters to poll (comma separated list, default all the clusters)")
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if *flInterval <= 0 {
+		return errors.Str("please provide a positive interval")
+	}
+
+	collector := &metricsCollector{clusters: flClusters}
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collector); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	srv := &http.Server{Addr: *flListen, Handler: mux}
+
+	go func() {
+		ticker := time.NewTicker(*flInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := collector.poll(); err != nil {
+				log.Printf("unable to poll reaper: %s", describeError(err))
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				srv.Close()
+				return
+			}
+		}
+	}()
+
+	log.Printf("serving metrics on %s/metrics", *flListen)
+
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return errors.E(errors.IO, "serveMetrics", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vrischmann/happyreaper/reaper"
+)
+
+func TestIsScheduledBy(t *testing.T) {
+	testCases := []struct {
+		cause string
+		id    string
+		exp   bool
+	}{
+		{"scheduled run (schedule id 1)", "1", true},
+		{"scheduled run (schedule id 12)", "1", false},
+		{"scheduled run (schedule id 12)", "12", true},
+		{"scheduled run (schedule id 1)", "12", false},
+		{"scheduled run (schedule id 5f2a9c10-0b1e-11e9-8d2b-3b1b0d6d3b2a)", "5f2a9c10-0b1e-11e9-8d2b-3b1b0d6d3b2a", true},
+		{"manual run", "1", false},
+	}
+
+	for _, tc := range testCases {
+		if got := isScheduledBy(reaper.RepairRun{Cause: tc.cause}, tc.id); got != tc.exp {
+			t.Errorf("isScheduledBy(%q, %q): got %v, expected %v", tc.cause, tc.id, got, tc.exp)
+		}
+	}
+}
+
+func TestLastActivation(t *testing.T) {
+	at := func(hours int) *time.Time {
+		t := testNow.Add(time.Duration(hours) * time.Hour)
+		return &t
+	}
+
+	cl := reaper.Cluster{RepairRuns: []reaper.RepairRun{
+		{Cause: "scheduled run (schedule id 1)", CreationTime: at(-48)},
+		{Cause: "scheduled run (schedule id 12)", CreationTime: at(-1)},
+		{Cause: "scheduled run (schedule id 1)", CreationTime: at(-24)},
+		{Cause: "scheduled run (schedule id 13)", CreationTime: nil},
+	}}
+
+	testCases := []struct {
+		id  string
+		exp *time.Time
+	}{
+		{"1", at(-24)},
+		{"12", at(-1)},
+		{"13", nil},
+		{"2", nil},
+	}
+
+	for _, tc := range testCases {
+		res := lastActivation(cl, reaper.RepairSchedule{ID: tc.id})
+		switch {
+		case res == nil && tc.exp == nil:
+		case res == nil || tc.exp == nil || !res.Equal(*tc.exp):
+			t.Errorf("schedule %s: got %v, expected %v", tc.id, res, tc.exp)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vrischmann/happyreaper/errors"
+	"github.com/vrischmann/happyreaper/reaper"
+	yaml "gopkg.in/yaml.v2"
+)
+
+type OutputFormat string
+
+const (
+	OutputText OutputFormat = "text"
+	OutputJSON OutputFormat = "json"
+	OutputYAML OutputFormat = "yaml"
+	OutputCSV  OutputFormat = "csv"
+)
+
+func (f OutputFormat) String() string { return string(f) }
+
+func (f *OutputFormat) Set(s string) error {
+	switch {
+	case strings.EqualFold(s, "text"):
+		*f = OutputText
+	case strings.EqualFold(s, "json"):
+		*f = OutputJSON
+	case strings.EqualFold(s, "yaml"):
+		*f = OutputYAML
+	case strings.EqualFold(s, "csv"):
+		*f = OutputCSV
+	default:
+		return errors.Errorf("invalid output format %q", s)
+	}
+	return nil
+}
+
+// writeOutput serializes v to w using format.
+// It must not be called with OutputText, each command prints text itself.
+func writeOutput(w io.Writer, format OutputFormat, v interface{}) error {
+	const op = "writeOutput"
+
+	switch format {
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			return errors.E(errors.IO, op, err)
+		}
+		return nil
+
+	case OutputYAML:
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return errors.E(errors.IO, op, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return errors.E(errors.IO, op, err)
+		}
+		return nil
+
+	case OutputCSV:
+		return writeCSV(w, v)
+
+	default:
+		return errors.Errorf("invalid output format %q", format)
+	}
+}
+
+func writeCSV(w io.Writer, v interface{}) error {
+	const op = "writeCSV"
+
+	var records [][]string
+
+	switch v := v.(type) {
+	case []string:
+		records = append(records, []string{"name"})
+		for _, s := range v {
+			records = append(records, []string{s})
+		}
+
+	case reaper.RepairRun:
+		return writeCSV(w, []reaper.RepairRun{v})
+
+	case []reaper.RepairRun:
+		records = append(records, runFieldNames())
+		for _, run := range v {
+			records = append(records, runRecord(run))
+		}
+
+	case repairReplacement:
+		records = append(records, append([]string{"old_id", "new_id"}, runFieldNames()...))
+		records = append(records, append([]string{v.OldID, v.NewID}, runRecord(v.Run)...))
+
+	case reaper.RepairSchedule:
+		return writeCSV(w, []reaper.RepairSchedule{v})
+
+	case []reaper.RepairSchedule:
+		records = append(records, scheduleFieldNames())
+		for _, sched := range v {
+			records = append(records, scheduleRecord(sched))
+		}
+
+	case []reaper.Segment:
+		records = append(records, segmentFieldNames())
+		for _, seg := range v {
+			records = append(records, segmentRecord(seg))
+		}
+
+	case []bulkResult:
+		records = append(records, []string{"id", "error"})
+		for _, res := range v {
+			records = append(records, []string{res.ID, res.Error})
+		}
+
+	case []runProgress:
+		records = append(records, []string{"id", "cluster_name", "keyspace_name", "total_segments", "from_segments_repaired", "to_segments_repaired", "since", "rate_per_hour", "status"})
+		for _, p := range v {
+			var since string
+			if !p.Since.IsZero() {
+				since = p.Since.Format(time.RFC3339)
+			}
+			records = append(records, []string{
+				p.ID, p.Cluster, p.Keyspace,
+				strconv.Itoa(p.Total), strconv.Itoa(p.From), strconv.Itoa(p.To),
+				since, strconv.FormatFloat(p.Rate, 'f', 2, 64), p.Status,
+			})
+		}
+
+	case []activation:
+		records = append(records, []string{"time", "schedule_id", "cluster_name", "keyspace_name", "column_families", "segment_count"})
+		for _, act := range v {
+			records = append(records, []string{
+				act.Time.Format(time.RFC3339), act.ScheduleID, act.Cluster, act.Keyspace,
+				strings.Join(act.Tables, ","), strconv.Itoa(act.Segments),
+			})
+		}
+
+	case []scheduleChange:
+		// One row per changed field, the creations and deletions have a single row without a field.
+		records = append(records, []string{"action", "key", "id", "field", "before", "after"})
+		for _, c := range v {
+			if len(c.Changes) == 0 {
+				records = append(records, []string{c.Action, c.Key, c.ID, "", "", ""})
+			}
+			for _, fc := range c.Changes {
+				records = append(records, []string{c.Action, c.Key, c.ID, fc.Field, fc.Before, fc.After})
+			}
+		}
+
+	case []hostFailures:
+		records = append(records, []string{"host", "segments", "failed_segments", "failures"})
+		for _, hf := range v {
+			records = append(records, []string{hf.Host, strconv.Itoa(hf.Segments), strconv.Itoa(hf.FailedSegments), strconv.Itoa(hf.Failures)})
+		}
+
+	default:
+		return errors.Errorf("csv output is not supported for %T", v)
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(records); err != nil {
+		return errors.E(errors.IO, op, err)
+	}
+
+	return nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+type runField struct {
+	name string
+	get  func(r reaper.RepairRun) string
+}
+
+// runFields lists every field of a RepairRun in the order they are printed.
+var runFields = []runField{
+	{"id", func(r reaper.RepairRun) string { return r.ID }},
+	{"owner", func(r reaper.RepairRun) string { return r.Owner }},
+	{"cluster_name", func(r reaper.RepairRun) string { return r.ClusterName }},
+	{"keyspace_name", func(r reaper.RepairRun) string { return r.KeyspaceName }},
+	{"state", func(r reaper.RepairRun) string { return r.State.String() }},
+	{"cause", func(r reaper.RepairRun) string { return r.Cause }},
+	{"column_families", func(r reaper.RepairRun) string { return strings.Join(r.ColumnFamilies, ",") }},
+	{"intensity", func(r reaper.RepairRun) string { return fmt.Sprintf("%0.3f", r.Intensity) }},
+	{"total_segments", func(r reaper.RepairRun) string { return strconv.Itoa(r.TotalSegments) }},
+	{"segments_repaired", func(r reaper.RepairRun) string { return strconv.Itoa(r.SegmentsRepaired) }},
+	{"last_event", func(r reaper.RepairRun) string { return r.LastEvent }},
+	{"duration", func(r reaper.RepairRun) string { return r.Duration }},
+	{"repair_parallelism", func(r reaper.RepairRun) string { return r.RepairParallelism.String() }},
+	{"incremental_repair", func(r reaper.RepairRun) string { return strconv.FormatBool(r.IncrementalRepair) }},
+	{"nodes", func(r reaper.RepairRun) string { return strings.Join(r.Nodes, ",") }},
+	{"datacenters", func(r reaper.RepairRun) string { return strings.Join(r.Datacenters, ",") }},
+	{"blacklisted_tables", func(r reaper.RepairRun) string { return strings.Join(r.BlacklistedTables, ",") }},
+	{"creation_time", func(r reaper.RepairRun) string { return formatTime(r.CreationTime) }},
+	{"start_time", func(r reaper.RepairRun) string { return formatTime(r.StartTime) }},
+	{"end_time", func(r reaper.RepairRun) string { return formatTime(r.EndTime) }},
+	{"pause_time", func(r reaper.RepairRun) string { return formatTime(r.PauseTime) }},
+}
+
+func runFieldNames() []string {
+	res := make([]string, 0, len(runFields))
+	for _, f := range runFields {
+		res = append(res, f.name)
+	}
+	return res
+}
+
+func runRecord(r reaper.RepairRun) []string {
+	res := make([]string, 0, len(runFields))
+	for _, f := range runFields {
+		res = append(res, f.get(r))
+	}
+	return res
+}
+
+type scheduleField struct {
+	name string
+	get  func(r reaper.RepairSchedule) string
+}
+
+// scheduleFields lists every field of a RepairSchedule in the order they are printed.
+var scheduleFields = []scheduleField{
+	{"id", func(r reaper.RepairSchedule) string { return r.ID }},
+	{"owner", func(r reaper.RepairSchedule) string { return r.Owner }},
+	{"cluster_name", func(r reaper.RepairSchedule) string { return r.ClusterName }},
+	{"keyspace_name", func(r reaper.RepairSchedule) string { return r.KeyspaceName }},
+	{"state", func(r reaper.RepairSchedule) string { return r.State.String() }},
+	{"column_families", func(r reaper.RepairSchedule) string { return strings.Join(r.ColumnFamilies, ",") }},
+	{"intensity", func(r reaper.RepairSchedule) string { return fmt.Sprintf("%0.3f", r.Intensity) }},
+	{"incremental_repair", func(r reaper.RepairSchedule) string { return strconv.FormatBool(r.IncrementalRepair) }},
+	{"repair_parallelism", func(r reaper.RepairSchedule) string { return r.RepairParallelism.String() }},
+	{"scheduled_days_between", func(r reaper.RepairSchedule) string { return strconv.Itoa(r.ScheduledDaysBetween) }},
+	{"segment_count", func(r reaper.RepairSchedule) string { return strconv.Itoa(r.SegmentCount) }},
+	{"segment_count_per_node", func(r reaper.RepairSchedule) string { return strconv.Itoa(r.SegmentCountPerNode) }},
+	{"creation_time", func(r reaper.RepairSchedule) string { return formatTime(r.CreationTime) }},
+	{"pause_time", func(r reaper.RepairSchedule) string { return formatTime(r.PauseTime) }},
+	{"next_activation", func(r reaper.RepairSchedule) string { return formatTime(r.NextActivation) }},
+}
+
+func scheduleFieldNames() []string {
+	res := make([]string, 0, len(scheduleFields))
+	for _, f := range scheduleFields {
+		res = append(res, f.name)
+	}
+	return res
+}
+
+func scheduleRecord(r reaper.RepairSchedule) []string {
+	res := make([]string, 0, len(scheduleFields))
+	for _, f := range scheduleFields {
+		res = append(res, f.get(r))
+	}
+	return res
+}
+
+type segmentField struct {
+	name string
+	get  func(s reaper.Segment) string
+}
+
+// segmentFields lists every field of a Segment in the order they are printed.
+var segmentFields = []segmentField{
+	{"id", func(s reaper.Segment) string { return s.ID }},
+	{"run_id", func(s reaper.Segment) string { return s.RunID }},
+	{"start_token", func(s reaper.Segment) string { return s.TokenRange.Start }},
+	{"end_token", func(s reaper.Segment) string { return s.TokenRange.End }},
+	{"state", func(s reaper.Segment) string { return s.State.String() }},
+	{"coordinator_host", func(s reaper.Segment) string { return s.CoordinatorHost }},
+	{"fail_count", func(s reaper.Segment) string { return strconv.Itoa(s.FailCount) }},
+	{"start_time", func(s reaper.Segment) string { return formatTime(s.StartTime) }},
+	{"end_time", func(s reaper.Segment) string { return formatTime(s.EndTime) }},
+}
+
+func segmentFieldNames() []string {
+	res := make([]string, 0, len(segmentFields))
+	for _, f := range segmentFields {
+		res = append(res, f.name)
+	}
+	return res
+}
+
+func segmentRecord(s reaper.Segment) []string {
+	res := make([]string, 0, len(segmentFields))
+	for _, f := range segmentFields {
+		res = append(res, f.get(s))
+	}
+	return res
+}
+
+// printChanges prints the fields whose value differ between the before and after records.
+func printChanges(w io.Writer, names, before, after []string) {
+	var changed bool
+	for i, name := range names {
+		if before[i] == after[i] {
+			continue
+		}
+		fmt.Fprintf(w, "%-25s %s -> %s\n", name+":", before[i], after[i])
+		changed = true
+	}
+
+	if !changed {
+		fmt.Fprintln(w, "nothing changed")
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteCSVScheduleChanges(t *testing.T) {
+	testCases := []struct {
+		name    string
+		changes []scheduleChange
+		exp     string
+	}{
+		{
+			"no changes",
+			nil,
+			"action,key,id,field,before,after\n",
+		},
+		{
+			"create and delete",
+			[]scheduleChange{
+				{Action: actionCreate, Key: "c1/ks1"},
+				{Action: actionDelete, Key: "c1/ks2", ID: "2"},
+			},
+			"action,key,id,field,before,after\ncreate,c1/ks1,,,,\ndelete,c1/ks2,2,,,\n",
+		},
+		{
+			"one row per field",
+			[]scheduleChange{
+				{Action: actionUpdate, Key: "c1/ks1 [t1,t2]", ID: "1", Changes: []fieldChange{
+					{Field: "owner", Before: "ops", After: "dev"},
+					{Field: "intensity", Before: "0.500", After: "0.900"},
+				}},
+			},
+			"action,key,id,field,before,after\nupdate,\"c1/ks1 [t1,t2]\",1,owner,ops,dev\nupdate,\"c1/ks1 [t1,t2]\",1,intensity,0.500,0.900\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeCSV(&buf, tc.changes); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tc.exp {
+				t.Errorf("got %q, expected %q", got, tc.exp)
+			}
+		})
+	}
+}
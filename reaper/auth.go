@@ -0,0 +1,130 @@
+package reaper
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/vrischmann/happyreaper/errors"
+)
+
+type AuthMethod string
+
+const (
+	// AuthLogin logs in with the /login endpoint and then uses the session cookie,
+	// and the JWT if the Reaper version provides one.
+	AuthLogin AuthMethod = "login"
+	// AuthBasic sends the credentials with HTTP basic authentication on every request.
+	AuthBasic AuthMethod = "basic"
+)
+
+func (m AuthMethod) String() string { return string(m) }
+
+func (m *AuthMethod) Set(s string) error {
+	switch {
+	case strings.EqualFold(s, "login"):
+		*m = AuthLogin
+	case strings.EqualFold(s, "basic"):
+		*m = AuthBasic
+	default:
+		return errors.Errorf("invalid auth method %q", s)
+	}
+	return nil
+}
+
+// Credentials are used to authenticate against a Reaper instance with authentication enabled.
+type Credentials struct {
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+}
+
+// IsZero returns true if no username is set.
+func (c Credentials) IsZero() bool {
+	return c.Username == ""
+}
+
+// session holds the state of a login session.
+// The session cookie itself is kept in the cookie jar of the HTTP client.
+type session struct {
+	mu       sync.Mutex
+	loggedIn bool
+	token    string
+}
+
+func (s *session) get() (loggedIn bool, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loggedIn, s.token
+}
+
+func (s *session) set(loggedIn bool, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loggedIn, s.token = loggedIn, token
+}
+
+// authenticate adds the authentication headers to req.
+func (c *Client) authenticate(req *http.Request) {
+	switch {
+	case c.creds.IsZero():
+	case c.auth == AuthBasic:
+		req.SetBasicAuth(c.creds.Username, c.creds.Password)
+	case c.auth == AuthLogin:
+		if _, token := c.sess.get(); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+}
+
+// ensureLoggedIn logs in if the client uses AuthLogin and isn't logged in yet.
+func (c *Client) ensureLoggedIn(ctx context.Context) error {
+	if c.creds.IsZero() || c.auth != AuthLogin {
+		return nil
+	}
+	if loggedIn, _ := c.sess.get(); loggedIn {
+		return nil
+	}
+	return c.Login(ctx)
+}
+
+// Login authenticates against Reaper with the credentials of the client.
+//
+// It is called automatically before the first request and when the session expires,
+// calling it explicitly is only useful to check the credentials.
+func (c *Client) Login(ctx context.Context) error {
+	const op = "Login"
+
+	if c.creds.IsZero() {
+		return errors.E(errors.Invalid, op, errors.Str("no credentials configured"))
+	}
+
+	form := make(url.Values)
+	form.Add("username", c.creds.Username)
+	form.Add("password", c.creds.Password)
+	form.Add("rememberMe", "false")
+
+	resp, body, err := c.roundTrip(ctx, op, "POST", "/login", nil, formPayload(form))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return apiError(op, resp, body)
+	}
+
+	// Only newer Reaper versions hand out JWTs, older ones rely on the session cookie alone.
+	var token string
+
+	resp, body, err = c.roundTrip(ctx, op, "GET", "/jwt", nil, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusOK {
+		token = strings.TrimSpace(string(body))
+	}
+
+	c.sess.set(true, token)
+
+	return nil
+}
@@ -0,0 +1,363 @@
+// Package reaper implements a client for the cassandra-reaper REST API.
+package reaper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vrischmann/happyreaper/errors"
+)
+
+// Config contains the optional settings of a Client.
+type Config struct {
+	// Scheme is either http or https. Defaults to http.
+	Scheme string
+	// HTTPClient is used to make all requests. Defaults to http.DefaultClient.
+	//
+	// Use it to configure TLS, see TLSOptions.
+	HTTPClient *http.Client
+
+	// Credentials are used when Reaper has authentication enabled.
+	Credentials Credentials
+	// Auth is the authentication method used with Credentials. Defaults to AuthLogin.
+	Auth AuthMethod
+
+	// Timeout limits the duration of each attempt of a request on a host. Zero means no timeout.
+	Timeout time.Duration
+	// Retries is the number of times an idempotent request (GET or PUT) is retried when all hosts failed.
+	Retries int
+	// Backoff is the delay before the first retry, it is doubled for each following retry. Defaults to 500ms.
+	Backoff time.Duration
+
+	// Logf, if set, is used to report which host served each request and the failovers.
+	Logf func(format string, args ...interface{})
+}
+
+// Client talks to a Reaper instance, possibly deployed on multiple hosts.
+type Client struct {
+	scheme string
+	hosts  []string
+	hc     *http.Client
+	logf   func(format string, args ...interface{})
+
+	timeout time.Duration
+	retries int
+	backoff time.Duration
+
+	auth  AuthMethod
+	creds Credentials
+	sess  session
+
+	mu      sync.Mutex
+	current int
+}
+
+// NewClient creates a client for the Reaper instance reachable at hosts (in the host:port form).
+//
+// Requests are sent to the first host until it fails, in which case the following hosts are tried in order.
+// The host which answered last is used first for the next requests.
+func NewClient(hosts []string, conf Config) *Client {
+	c := &Client{
+		scheme: conf.Scheme,
+		hosts:  hosts,
+		hc:     conf.HTTPClient,
+		logf:   conf.Logf,
+		auth:   conf.Auth,
+		creds:  conf.Credentials,
+
+		timeout: conf.Timeout,
+		retries: conf.Retries,
+		backoff: conf.Backoff,
+	}
+	if c.scheme == "" {
+		c.scheme = "http"
+	}
+	if c.hc == nil {
+		c.hc = http.DefaultClient
+	}
+	if c.auth == "" {
+		c.auth = AuthLogin
+	}
+	if c.logf == nil {
+		c.logf = func(string, ...interface{}) {}
+	}
+	if c.backoff <= 0 {
+		c.backoff = 500 * time.Millisecond
+	}
+
+	// The login session is tracked with a cookie, make sure we don't share a jar with other users of the HTTP client.
+	if !c.creds.IsZero() && c.auth == AuthLogin {
+		hc := *c.hc
+		hc.Jar, _ = cookiejar.New(nil)
+		c.hc = &hc
+	}
+
+	return c
+}
+
+func (c *Client) makeURL(host, path string, qry url.Values) string {
+	ur := c.scheme + "://" + host + path
+	if len(qry) > 0 {
+		ur += "?" + qry.Encode()
+	}
+	return ur
+}
+
+func (c *Client) currentHost() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current
+}
+
+func (c *Client) setCurrentHost(i int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = i
+}
+
+// payload is the body of a request.
+type payload struct {
+	contentType string
+	data        []byte
+}
+
+func formPayload(form url.Values) *payload {
+	return &payload{
+		contentType: "application/x-www-form-urlencoded",
+		data:        []byte(form.Encode()),
+	}
+}
+
+func jsonPayload(v interface{}) (*payload, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &payload{
+		contentType: "application/json",
+		data:        data,
+	}, nil
+}
+
+// roundTrip executes the request and returns the response along with its body.
+// If pl is not nil it is sent as the request body.
+//
+// The request is tried on each host in turn if it can't connect or if the response is a server error.
+// Non idempotent requests are only tried on the next host if the connection couldn't be established,
+// since the first host might have executed them.
+//
+// If all hosts failed, idempotent requests are tried again on all hosts up to c.retries times,
+// waiting with an exponential backoff between each round.
+func (c *Client) roundTrip(ctx context.Context, op, method, path string, qry url.Values, pl *payload) (*http.Response, []byte, error) {
+	if len(c.hosts) == 0 {
+		return nil, nil, errors.E(errors.Invalid, op, errors.Str("no reaper host configured"))
+	}
+
+	attempts := 1
+	if method == "GET" || method == "PUT" {
+		attempts += c.retries
+	}
+
+	var (
+		resp *http.Response
+		body []byte
+		err  error
+		ok   bool
+	)
+
+	delay := c.backoff
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			c.logf("%s %s failed on all hosts, retrying in %s", method, path, delay)
+
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, nil, errors.E(errors.IO, op, ctx.Err())
+			}
+			delay *= 2
+		}
+
+		resp, body, ok, err = c.tryHosts(ctx, op, method, path, qry, pl)
+		if ok || ctx.Err() != nil {
+			break
+		}
+	}
+
+	return resp, body, err
+}
+
+// tryHosts executes the request on each host in turn, starting with the current one, until one of them serves it.
+// ok is false if no host served the request, in which case the last response or error is returned.
+func (c *Client) tryHosts(ctx context.Context, op, method, path string, qry url.Values, pl *payload) (resp *http.Response, body []byte, ok bool, err error) {
+	start := c.currentHost()
+
+	for i := range c.hosts {
+		idx := (start + i) % len(c.hosts)
+		host := c.hosts[idx]
+
+		resp, body, err = c.send(ctx, op, host, method, path, qry, pl)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return nil, nil, false, err
+
+		case err != nil && method == "POST" && !dialFailed(err):
+			c.logf("%s %s failed on %s after being sent: %v", method, path, host, err)
+			return nil, nil, false, err
+
+		case err != nil:
+			c.logf("%s %s failed on %s: %v", method, path, host, err)
+			continue
+
+		case resp.StatusCode >= 500 && method != "POST":
+			c.logf("%s %s failed on %s: %s", method, path, host, resp.Status)
+			continue
+		}
+
+		c.logf("%s %s served by %s", method, path, host)
+		c.setCurrentHost(idx)
+
+		return resp, body, true, nil
+	}
+
+	return resp, body, false, err
+}
+
+// dialFailed returns true if err happened while connecting to the host, in which case the request was never sent.
+func dialFailed(err error) bool {
+	if e, ok := err.(*errors.Error); ok {
+		err = e.Err
+	}
+	if e, ok := err.(*url.Error); ok {
+		err = e.Err
+	}
+	e, ok := err.(*net.OpError)
+	return ok && e.Op == "dial"
+}
+
+// send executes the request on host.
+// The whole exchange, including reading the response body, is limited by c.timeout.
+func (c *Client) send(ctx context.Context, op, host, method, path string, qry url.Values, pl *payload) (*http.Response, []byte, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	var body io.Reader
+	if pl != nil {
+		body = bytes.NewReader(pl.data)
+	}
+
+	req, err := http.NewRequest(method, c.makeURL(host, path, qry), body)
+	if err != nil {
+		return nil, nil, errors.E(errors.Invalid, op, err)
+	}
+	req = req.WithContext(ctx)
+
+	if pl != nil {
+		req.Header.Set("Content-Type", pl.contentType)
+	}
+	c.authenticate(req)
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, nil, errors.E(errors.IO, op, err)
+	}
+	defer resp.Body.Close()
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, resp.Body); err != nil {
+		return nil, nil, errors.E(errors.IO, op, err)
+	}
+
+	return resp, buf.Bytes(), nil
+}
+
+// apiError builds the error returned when Reaper answers with an unexpected status code.
+// Its kind depends on the status code so that callers can tell a missing resource from an invalid request.
+func apiError(op string, resp *http.Response, body []byte) error {
+	kind := errors.API
+	switch resp.StatusCode {
+	case http.StatusBadRequest:
+		kind = errors.Invalid
+	case http.StatusNotFound:
+		kind = errors.NotFound
+	case http.StatusConflict:
+		kind = errors.Conflict
+	}
+
+	return errors.E(kind, op, &errors.HTTPError{
+		StatusCode: resp.StatusCode,
+		Method:     resp.Request.Method,
+		URL:        resp.Request.URL.String(),
+		Message:    parseMessage(body),
+	})
+}
+
+// parseMessage extracts the error message from a response body.
+// Reaper answers either with a JSON object containing a message or with plain text.
+func parseMessage(body []byte) string {
+	var res struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &res); err == nil && res.Message != "" {
+		return res.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// do executes the request and decodes the JSON response body into res.
+// The response status code must be equal to status, otherwise the error returned contains an *errors.HTTPError.
+// res can be nil if the caller doesn't care about the response body.
+func (c *Client) do(ctx context.Context, op, method, path string, qry url.Values, status int, res interface{}) error {
+	return c.doWithPayload(ctx, op, method, path, qry, nil, status, res)
+}
+
+// doWithPayload is like do but sends pl as the request body.
+func (c *Client) doWithPayload(ctx context.Context, op, method, path string, qry url.Values, pl *payload, status int, res interface{}) error {
+	if err := c.ensureLoggedIn(ctx); err != nil {
+		return err
+	}
+
+	resp, body, err := c.roundTrip(ctx, op, method, path, qry, pl)
+	if err != nil {
+		return err
+	}
+
+	// The session probably expired, log in again and retry once.
+	if resp.StatusCode == http.StatusUnauthorized && !c.creds.IsZero() && c.auth == AuthLogin {
+		if err := c.Login(ctx); err != nil {
+			return err
+		}
+
+		resp, body, err = c.roundTrip(ctx, op, method, path, qry, pl)
+		if err != nil {
+			return err
+		}
+	}
+
+	if resp.StatusCode != status {
+		return apiError(op, resp, body)
+	}
+
+	// Some endpoints don't return anything.
+	if res == nil || len(body) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(body, res); err != nil {
+		return errors.E(errors.IO, op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,298 @@
+package reaper
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vrischmann/happyreaper/errors"
+)
+
+// testHost starts a server answering every request with the status returned by fn, which gets the number of the request starting at 1.
+func testHost(t *testing.T, fn func(n int) int) (string, *int32) {
+	t.Helper()
+
+	var count int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&count, 1)
+		w.WriteHeader(fn(int(n)))
+		w.Write([]byte(`["c1"]`))
+	}))
+	t.Cleanup(srv.Close)
+
+	return strings.TrimPrefix(srv.URL, "http://"), &count
+}
+
+// deadHost returns the address of a host refusing connections.
+func deadHost(t *testing.T) string {
+	t.Helper()
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	host := strings.TrimPrefix(srv.URL, "http://")
+	srv.Close()
+
+	return host
+}
+
+func status(code int) func(int) int { return func(int) int { return code } }
+
+func TestRoundTripFailover(t *testing.T) {
+	testCases := []struct {
+		name    string
+		method  string
+		first   func(int) int
+		dead    bool
+		status  int
+		current int
+		second  int32
+	}{
+		{"server error", "GET", status(http.StatusInternalServerError), false, http.StatusOK, 1, 1},
+		{"connection refused", "GET", nil, true, http.StatusOK, 1, 1},
+		{"client error", "GET", status(http.StatusNotFound), false, http.StatusNotFound, 0, 0},
+		{"post server error", "POST", status(http.StatusInternalServerError), false, http.StatusInternalServerError, 0, 0},
+		{"post connection refused", "POST", nil, true, http.StatusOK, 1, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var first string
+			if tc.dead {
+				first = deadHost(t)
+			} else {
+				first, _ = testHost(t, tc.first)
+			}
+			second, count := testHost(t, status(http.StatusOK))
+
+			c := NewClient([]string{first, second}, Config{})
+
+			resp, _, err := c.roundTrip(context.Background(), "test", tc.method, "/cluster", nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Errorf("got status %d, expected %d", resp.StatusCode, tc.status)
+			}
+			if got := c.currentHost(); got != tc.current {
+				t.Errorf("got current host %d, expected %d", got, tc.current)
+			}
+			if got := atomic.LoadInt32(count); got != tc.second {
+				t.Errorf("got %d requests on the second host, expected %d", got, tc.second)
+			}
+		})
+	}
+}
+
+// abortingHost returns the address of a host closing the connection once it received the request.
+func abortingHost(t *testing.T) (string, *int32) {
+	t.Helper()
+
+	var count int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		panic(http.ErrAbortHandler)
+	}))
+	t.Cleanup(srv.Close)
+
+	return strings.TrimPrefix(srv.URL, "http://"), &count
+}
+
+func TestRoundTripFailsAfterSent(t *testing.T) {
+	testCases := []struct {
+		method string
+		err    bool
+		second int32
+	}{
+		{"GET", false, 1},
+		{"PUT", false, 1},
+		{"POST", true, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.method, func(t *testing.T) {
+			first, firstCount := abortingHost(t)
+			second, count := testHost(t, status(http.StatusOK))
+
+			c := NewClient([]string{first, second}, Config{})
+
+			_, _, err := c.roundTrip(context.Background(), "test", tc.method, "/cluster", nil, nil)
+			if (err != nil) != tc.err {
+				t.Errorf("got error %v, expected error %v", err, tc.err)
+			}
+			if got := atomic.LoadInt32(firstCount); got != 1 {
+				t.Errorf("got %d requests on the first host, expected 1", got)
+			}
+			if got := atomic.LoadInt32(count); got != tc.second {
+				t.Errorf("got %d requests on the second host, expected %d", got, tc.second)
+			}
+		})
+	}
+}
+
+func TestDialFailed(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "reaper"}}
+	readErr := &net.OpError{Op: "read", Net: "tcp", Err: io.ErrUnexpectedEOF}
+
+	testCases := []struct {
+		name string
+		err  error
+		exp  bool
+	}{
+		{"dial", errors.E(errors.IO, "test", &url.Error{Op: "Post", URL: "http://reaper", Err: dialErr}), true},
+		{"unwrapped dial", dialErr, true},
+		{"read", errors.E(errors.IO, "test", &url.Error{Op: "Post", URL: "http://reaper", Err: readErr}), false},
+		{"eof", errors.E(errors.IO, "test", &url.Error{Op: "Post", URL: "http://reaper", Err: io.EOF}), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := dialFailed(tc.err); got != tc.exp {
+				t.Errorf("got %v, expected %v", got, tc.exp)
+			}
+		})
+	}
+}
+
+func TestRoundTripStartsWithCurrentHost(t *testing.T) {
+	first, firstCount := testHost(t, status(http.StatusOK))
+	second, secondCount := testHost(t, status(http.StatusOK))
+
+	c := NewClient([]string{first, second}, Config{})
+	c.setCurrentHost(1)
+
+	if _, _, err := c.roundTrip(context.Background(), "test", "GET", "/cluster", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(firstCount); got != 0 {
+		t.Errorf("got %d requests on the first host, expected 0", got)
+	}
+	if got := atomic.LoadInt32(secondCount); got != 1 {
+		t.Errorf("got %d requests on the second host, expected 1", got)
+	}
+}
+
+func TestRoundTripRetries(t *testing.T) {
+	// The host fails twice then succeeds.
+	failTwice := func(n int) int {
+		if n <= 2 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	}
+
+	testCases := []struct {
+		name     string
+		method   string
+		retries  int
+		status   int
+		requests int32
+	}{
+		{"enough retries", "GET", 2, http.StatusOK, 3},
+		{"not enough retries", "GET", 1, http.StatusServiceUnavailable, 2},
+		{"put is retried", "PUT", 2, http.StatusOK, 3},
+		{"delete is not retried", "DELETE", 2, http.StatusServiceUnavailable, 1},
+		{"no retries", "GET", 0, http.StatusServiceUnavailable, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			host, count := testHost(t, failTwice)
+
+			c := NewClient([]string{host}, Config{Retries: tc.retries, Backoff: time.Millisecond})
+
+			resp, _, err := c.roundTrip(context.Background(), "test", tc.method, "/cluster", nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Errorf("got status %d, expected %d", resp.StatusCode, tc.status)
+			}
+			if got := atomic.LoadInt32(count); got != tc.requests {
+				t.Errorf("got %d requests, expected %d", got, tc.requests)
+			}
+		})
+	}
+}
+
+func TestRoundTripAllHostsDown(t *testing.T) {
+	c := NewClient([]string{deadHost(t), deadHost(t)}, Config{Retries: 1, Backoff: time.Millisecond})
+
+	_, _, err := c.roundTrip(context.Background(), "test", "GET", "/cluster", nil, nil)
+	if kind := errors.KindOf(err); kind != errors.IO {
+		t.Errorf("got error %v of kind %s, expected %s", err, kind, errors.IO)
+	}
+}
+
+func TestRoundTripNoHost(t *testing.T) {
+	c := NewClient(nil, Config{})
+
+	_, _, err := c.roundTrip(context.Background(), "test", "GET", "/cluster", nil, nil)
+	if kind := errors.KindOf(err); kind != errors.Invalid {
+		t.Errorf("got error %v of kind %s, expected %s", err, kind, errors.Invalid)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	testCases := []struct {
+		status  int
+		body    string
+		kind    errors.Kind
+		message string
+	}{
+		{http.StatusBadRequest, `{"message": "invalid intensity"}`, errors.Invalid, "invalid intensity"},
+		{http.StatusNotFound, "cluster not found\n", errors.NotFound, "cluster not found"},
+		{http.StatusConflict, `{"message": "already running"}`, errors.Conflict, "already running"},
+		{http.StatusInternalServerError, "", errors.API, ""},
+		{http.StatusForbidden, "denied", errors.API, "denied"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(http.StatusText(tc.status), func(t *testing.T) {
+			req := httptest.NewRequest("PUT", "http://reaper:8080/repair_run/1", nil)
+			resp := &http.Response{StatusCode: tc.status, Request: req}
+
+			err := apiError("test", resp, []byte(tc.body))
+
+			if kind := errors.KindOf(err); kind != tc.kind {
+				t.Errorf("got kind %s, expected %s", kind, tc.kind)
+			}
+
+			he, ok := errors.AsHTTPError(err)
+			if !ok {
+				t.Fatalf("got error %v, expected a HTTP error", err)
+			}
+			if he.StatusCode != tc.status || he.Method != "PUT" || he.URL != "http://reaper:8080/repair_run/1" {
+				t.Errorf("got %+v, expected the status and request", he)
+			}
+			if he.Message != tc.message {
+				t.Errorf("got message %q, expected %q", he.Message, tc.message)
+			}
+		})
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	testCases := []struct {
+		body string
+		exp  string
+	}{
+		{`{"message": "no such cluster"}`, "no such cluster"},
+		{`{"message": ""}`, `{"message": ""}`},
+		{`{"error": "boom"}`, `{"error": "boom"}`},
+		{"  plain text\n", "plain text"},
+		{"", ""},
+		{`["not", "an", "object"]`, `["not", "an", "object"]`},
+	}
+
+	for _, tc := range testCases {
+		if got := parseMessage([]byte(tc.body)); got != tc.exp {
+			t.Errorf("parseMessage(%q): got %q, expected %q", tc.body, got, tc.exp)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package reaper
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type Cluster struct {
+	Name            string           `json:"name" yaml:"name"`
+	SeedHosts       []string         `json:"seed_hosts" yaml:"seed_hosts"`
+	RepairRuns      []RepairRun      `json:"repair_runs" yaml:"repair_runs"`
+	RepairSchedules []RepairSchedule `json:"repair_schedules" yaml:"repair_schedules"`
+}
+
+// ListClusters returns the name of all clusters known to Reaper.
+func (c *Client) ListClusters(ctx context.Context) ([]string, error) {
+	const op = "ListClusters"
+
+	var res []string
+	err := c.do(ctx, op, "GET", "/cluster", nil, http.StatusOK, &res)
+
+	return res, err
+}
+
+// GetCluster returns the cluster named name, including its repair runs and schedules.
+func (c *Client) GetCluster(ctx context.Context, name string) (Cluster, error) {
+	const op = "GetCluster"
+
+	var res Cluster
+	err := c.do(ctx, op, "GET", "/cluster/"+name, nil, http.StatusOK, &res)
+
+	return res, err
+}
+
+// AddCluster registers a new cluster using seedHost to discover it.
+func (c *Client) AddCluster(ctx context.Context, seedHost string) (Cluster, error) {
+	const op = "AddCluster"
+
+	qry := make(url.Values)
+	qry.Add("seedHost", seedHost)
+
+	var res Cluster
+	err := c.do(ctx, op, "POST", "/cluster", qry, http.StatusOK, &res)
+
+	return res, err
+}
+
+// UpdateClusterSeeds replaces the seed hosts of the cluster named name.
+func (c *Client) UpdateClusterSeeds(ctx context.Context, name string, seedHosts []string) (Cluster, error) {
+	const op = "UpdateClusterSeeds"
+
+	qry := make(url.Values)
+	qry.Add("seedHost", strings.Join(seedHosts, ","))
+
+	var res Cluster
+	err := c.do(ctx, op, "PUT", "/cluster/"+name, qry, http.StatusOK, &res)
+
+	return res, err
+}
+
+// DeleteCluster deletes the cluster named name.
+//
+// Reaper refuses to delete a cluster which still has repair runs or schedules unless force is true.
+// Older Reaper versions ignore force and always refuse.
+func (c *Client) DeleteCluster(ctx context.Context, name string, force bool) error {
+	const op = "DeleteCluster"
+
+	qry := make(url.Values)
+	if force {
+		qry.Add("force", "true")
+	}
+
+	return c.do(ctx, op, "DELETE", "/cluster/"+name, qry, http.StatusAccepted, nil)
+}
@@ -0,0 +1,259 @@
+package reaper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vrischmann/happyreaper/errors"
+)
+
+type Parallelism string
+
+func (p Parallelism) String() string { return string(p) }
+
+func (p *Parallelism) Set(s string) error {
+	switch {
+	case strings.EqualFold(s, "sequential"):
+		*p = Sequential
+	case strings.EqualFold(s, "parallel"):
+		*p = Parallel
+	case strings.EqualFold(s, "datacenter_aware"):
+		*p = DatacenterAware
+	default:
+		return errors.Errorf("invalid parallelism %q", s)
+	}
+	return nil
+}
+
+const (
+	Sequential      Parallelism = "SEQUENTIAL"
+	Parallel        Parallelism = "PARALLEL"
+	DatacenterAware Parallelism = "DATACENTER_AWARE"
+)
+
+type RunState string
+
+const (
+	NotStarted RunState = "NOT_STARTED"
+	Running    RunState = "RUNNING"
+	Error      RunState = "ERROR"
+	Done       RunState = "DONE"
+	Paused     RunState = "PAUSED"
+	Aborted    RunState = "ABORTED"
+	Deleted    RunState = "DELETED"
+)
+
+func (s RunState) String() string { return string(s) }
+
+func (s *RunState) Set(str string) error {
+	switch {
+	case strings.EqualFold(str, "not_started"):
+		*s = NotStarted
+	case strings.EqualFold(str, "running"):
+		*s = Running
+	case strings.EqualFold(str, "error"):
+		*s = Error
+	case strings.EqualFold(str, "done"):
+		*s = Done
+	case strings.EqualFold(str, "paused"):
+		*s = Paused
+	case strings.EqualFold(str, "aborted"):
+		*s = Aborted
+	case strings.EqualFold(str, "deleted"):
+		*s = Deleted
+	default:
+		return errors.Errorf("invalid state %q", str)
+	}
+	return nil
+}
+
+type RepairRun struct {
+	ID    string `json:"id" yaml:"id"`
+	Owner string `json:"owner" yaml:"owner"`
+
+	ClusterName  string `json:"cluster_name" yaml:"cluster_name"`
+	KeyspaceName string `json:"keyspace_name" yaml:"keyspace_name"`
+
+	State RunState `json:"state" yaml:"state"`
+
+	Cause            string   `json:"cause" yaml:"cause"`
+	ColumnFamilies   []string `json:"column_families" yaml:"column_families"`
+	Intensity        float64  `json:"intensity" yaml:"intensity"`
+	TotalSegments    int      `json:"total_segments" yaml:"total_segments"`
+	SegmentsRepaired int      `json:"segments_repaired" yaml:"segments_repaired"`
+	LastEvent        string   `json:"last_event" yaml:"last_event"`
+	Duration         string   `json:"duration" yaml:"duration"`
+
+	RepairParallelism Parallelism `json:"repair_parallelism" yaml:"repair_parallelism"`
+	IncrementalRepair bool        `json:"incremental_repair" yaml:"incremental_repair"`
+	Nodes             []string    `json:"nodes" yaml:"nodes"`
+	Datacenters       []string    `json:"datacenters" yaml:"datacenters"`
+	BlacklistedTables []string    `json:"blacklisted_tables" yaml:"blacklisted_tables"`
+
+	CreationTime *time.Time `json:"creation_time" yaml:"creation_time"`
+	StartTime    *time.Time `json:"start_time" yaml:"start_time"`
+	EndTime      *time.Time `json:"end_time" yaml:"end_time"`
+	PauseTime    *time.Time `json:"pause_time" yaml:"pause_time"`
+}
+
+func (r RepairRun) String() string {
+	s := fmt.Sprintf("{id:%s owner:%q cluster:%q keyspace:%q state:%s cause:%q cf:%v intensity:%0.3f segments:%d repaired:%d lastEvent:%q duration:%q creation:%s start:%s end:%s pause:%s}",
+		r.ID, r.Owner,
+		r.ClusterName, r.KeyspaceName,
+		r.State, r.Cause,
+		r.ColumnFamilies, r.Intensity,
+		r.TotalSegments, r.SegmentsRepaired,
+		r.LastEvent, r.Duration,
+		r.CreationTime, r.StartTime,
+		r.EndTime, r.PauseTime,
+	)
+	return s
+}
+
+func (r RepairRun) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "%-20s %s\n", "id:", r.ID)
+			fmt.Fprintf(s, "%-20s %s\n", "owner:", r.Owner)
+			fmt.Fprintf(s, "%-20s %s\n", "cluster name:", r.ClusterName)
+			fmt.Fprintf(s, "%-20s %s\n", "keyspace name:", r.KeyspaceName)
+			fmt.Fprintf(s, "%-20s %s\n", "state:", r.State)
+			fmt.Fprintf(s, "%-20s %s\n", "cause:", r.Cause)
+			fmt.Fprintf(s, "%-20s %v\n", "column families:", r.ColumnFamilies)
+			fmt.Fprintf(s, "%-20s %0.3f\n", "intensity:", r.Intensity)
+			fmt.Fprintf(s, "%-20s %d\n", "total segments:", r.TotalSegments)
+			fmt.Fprintf(s, "%-20s %d\n", "segments repaired:", r.SegmentsRepaired)
+			fmt.Fprintf(s, "%-20s %s\n", "last event:", r.LastEvent)
+			fmt.Fprintf(s, "%-20s %s\n", "duration:", r.Duration)
+			fmt.Fprintf(s, "%-20s %s\n", "creation time:", r.CreationTime)
+			fmt.Fprintf(s, "%-20s %s\n", "start time:", r.StartTime)
+			fmt.Fprintf(s, "%-20s %s\n", "end time:", r.EndTime)
+			fmt.Fprintf(s, "%-20s %s\n", "pause time:", r.PauseTime)
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, r.String())
+	}
+}
+
+// ListRepairRuns returns all repair runs, optionally filtered by state.
+// An empty state means no filtering.
+func (c *Client) ListRepairRuns(ctx context.Context, state RunState) ([]RepairRun, error) {
+	const op = "ListRepairRuns"
+
+	qry := make(url.Values)
+	if state != "" {
+		qry.Add("state", state.String())
+	}
+
+	var res []RepairRun
+	err := c.do(ctx, op, "GET", "/repair_run", qry, http.StatusOK, &res)
+
+	return res, err
+}
+
+// GetRepairRun returns the repair run identified by id.
+func (c *Client) GetRepairRun(ctx context.Context, id string) (RepairRun, error) {
+	const op = "GetRepairRun"
+
+	var res RepairRun
+	err := c.do(ctx, op, "GET", "/repair_run/"+id, nil, http.StatusOK, &res)
+
+	return res, err
+}
+
+// AddRepairRunParams are the parameters used to create a new repair run.
+type AddRepairRunParams struct {
+	Cluster           string
+	Keyspace          string
+	Tables            []string
+	Owner             string
+	Cause             string
+	Segments          int
+	Parallelism       Parallelism
+	Intensity         float64
+	Incremental       bool
+	Nodes             []string
+	Datacenters       []string
+	BlacklistedTables []string
+}
+
+func (p AddRepairRunParams) values() url.Values {
+	qry := make(url.Values)
+	qry.Add("clusterName", p.Cluster)
+	qry.Add("keyspace", p.Keyspace)
+	if len(p.Tables) > 0 {
+		qry.Add("tables", strings.Join(p.Tables, ","))
+	}
+	qry.Add("owner", p.Owner)
+	qry.Add("cause", p.Cause)
+	qry.Add("segmentCount", strconv.Itoa(p.Segments))
+	qry.Add("repairParallelism", p.Parallelism.String())
+	qry.Add("intensity", fmt.Sprintf("%0.3f", p.Intensity))
+	qry.Add("incrementalRepair", fmt.Sprintf("%v", p.Incremental))
+	qry.Add("nodes", strings.Join(p.Nodes, ","))
+	qry.Add("datacenters", strings.Join(p.Datacenters, ","))
+	qry.Add("blacklistedTables", strings.Join(p.BlacklistedTables, ","))
+
+	return qry
+}
+
+// AddRepairRun creates a new repair run.
+// The run is created in the NOT_STARTED state, it needs to be resumed to start it.
+func (c *Client) AddRepairRun(ctx context.Context, params AddRepairRunParams) (RepairRun, error) {
+	const op = "AddRepairRun"
+
+	var res RepairRun
+	err := c.do(ctx, op, "POST", "/repair_run", params.values(), http.StatusCreated, &res)
+
+	return res, err
+}
+
+// ChangeRepairRunState changes the state of the repair run identified by id.
+func (c *Client) ChangeRepairRunState(ctx context.Context, id string, state RunState) (RepairRun, error) {
+	const op = "ChangeRepairRunState"
+
+	qry := make(url.Values)
+	qry.Add("state", state.String())
+
+	var res RepairRun
+	err := c.do(ctx, op, "PUT", "/repair_run/"+id, qry, http.StatusOK, &res)
+
+	return res, err
+}
+
+// ChangeRepairRunIntensity changes the intensity of the repair run identified by id.
+// Reaper only accepts it if the run is PAUSED or NOT_STARTED.
+func (c *Client) ChangeRepairRunIntensity(ctx context.Context, id string, intensity float64) (RepairRun, error) {
+	const op = "ChangeRepairRunIntensity"
+
+	qry := make(url.Values)
+	qry.Add("intensity", fmt.Sprintf("%0.3f", intensity))
+
+	var res RepairRun
+	err := c.do(ctx, op, "PUT", "/repair_run/"+id, qry, http.StatusOK, &res)
+
+	return res, err
+}
+
+// DeleteRepairRun deletes the repair run identified by id.
+// owner must be the owner of the run.
+func (c *Client) DeleteRepairRun(ctx context.Context, id, owner string) (RepairRun, error) {
+	const op = "DeleteRepairRun"
+
+	qry := make(url.Values)
+	qry.Add("owner", owner)
+
+	var res RepairRun
+	err := c.do(ctx, op, "DELETE", "/repair_run/"+id, qry, http.StatusOK, &res)
+
+	return res, err
+}
@@ -0,0 +1,232 @@
+package reaper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vrischmann/happyreaper/errors"
+)
+
+type ScheduleState string
+
+const (
+	SActive  ScheduleState = "ACTIVE"
+	SPaused  ScheduleState = "PAUSED"
+	SDeleted ScheduleState = "DELETED"
+)
+
+func (s ScheduleState) String() string { return string(s) }
+
+func (s *ScheduleState) Set(str string) error {
+	switch {
+	case strings.EqualFold(str, "active"):
+		*s = SActive
+	case strings.EqualFold(str, "paused"):
+		*s = SPaused
+	case strings.EqualFold(str, "deleted"):
+		*s = SDeleted
+	default:
+		return errors.Errorf("invalid state %q", str)
+	}
+	return nil
+}
+
+type RepairSchedule struct {
+	ID    string `json:"id" yaml:"id"`
+	Owner string `json:"owner" yaml:"owner"`
+
+	ClusterName  string `json:"cluster_name" yaml:"cluster_name"`
+	KeyspaceName string `json:"keyspace_name" yaml:"keyspace_name"`
+
+	State ScheduleState `json:"state" yaml:"state"`
+
+	ColumnFamilies       []string    `json:"column_families" yaml:"column_families"`
+	Intensity            float64     `json:"intensity" yaml:"intensity"`
+	IncrementalRepair    bool        `json:"incremental_repair" yaml:"incremental_repair"`
+	RepairParallelism    Parallelism `json:"repair_parallelism" yaml:"repair_parallelism"`
+	ScheduledDaysBetween int         `json:"scheduled_days_between" yaml:"scheduled_days_between"`
+	// Older Reaper versions report SegmentCount, newer ones SegmentCountPerNode instead.
+	SegmentCount        int `json:"segment_count" yaml:"segment_count"`
+	SegmentCountPerNode int `json:"segment_count_per_node" yaml:"segment_count_per_node"`
+
+	CreationTime   *time.Time `json:"creation_time" yaml:"creation_time"`
+	PauseTime      *time.Time `json:"pause_time" yaml:"pause_time"`
+	NextActivation *time.Time `json:"next_activation" yaml:"next_activation"`
+}
+
+func (r RepairSchedule) String() string {
+	s := fmt.Sprintf("{id:%s owner:%q cluster:%q keyspace:%q state:%s cf:%v intensity:%0.3f incremental:%v par:%s daysBetween:%d segments:%d segmentsPerNode:%d creation:%s pause:%s next:%s}",
+		r.ID, r.Owner,
+		r.ClusterName, r.KeyspaceName,
+		r.State, r.ColumnFamilies,
+		r.Intensity, r.IncrementalRepair, r.RepairParallelism,
+		r.ScheduledDaysBetween, r.SegmentCount, r.SegmentCountPerNode,
+		r.CreationTime, r.PauseTime, r.NextActivation,
+	)
+	return s
+}
+
+func (r RepairSchedule) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "%-20s %s\n", "id:", r.ID)
+			fmt.Fprintf(s, "%-20s %s\n", "owner:", r.Owner)
+			fmt.Fprintf(s, "%-20s %s\n", "cluster name:", r.ClusterName)
+			fmt.Fprintf(s, "%-20s %s\n", "keyspace name:", r.KeyspaceName)
+			fmt.Fprintf(s, "%-20s %s\n", "state:", r.State)
+			fmt.Fprintf(s, "%-20s %v\n", "column families:", r.ColumnFamilies)
+			fmt.Fprintf(s, "%-20s %0.3f\n", "intensity:", r.Intensity)
+			fmt.Fprintf(s, "%-20s %v\n", "incremental:", r.IncrementalRepair)
+			fmt.Fprintf(s, "%-20s %s\n", "par:", r.RepairParallelism)
+			fmt.Fprintf(s, "%-20s %d\n", "days between:", r.ScheduledDaysBetween)
+			fmt.Fprintf(s, "%-20s %d\n", "segments:", r.SegmentCount)
+			fmt.Fprintf(s, "%-20s %d\n", "segments per node:", r.SegmentCountPerNode)
+			fmt.Fprintf(s, "%-20s %s\n", "creation time:", r.CreationTime)
+			fmt.Fprintf(s, "%-20s %s\n", "pause time:", r.PauseTime)
+			fmt.Fprintf(s, "%-20s %s\n", "next activation:", r.NextActivation)
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, r.String())
+	}
+}
+
+// ListSchedules returns all repair schedules, optionally filtered by cluster and keyspace.
+// Empty parameters mean no filtering.
+func (c *Client) ListSchedules(ctx context.Context, cluster, keyspace string) ([]RepairSchedule, error) {
+	const op = "ListSchedules"
+
+	qry := make(url.Values)
+	if cluster != "" {
+		qry.Add("clusterName", cluster)
+	}
+	if keyspace != "" {
+		qry.Add("keyspaceName", keyspace)
+	}
+
+	var res []RepairSchedule
+	err := c.do(ctx, op, "GET", "/repair_schedule", qry, http.StatusOK, &res)
+
+	return res, err
+}
+
+// GetSchedule returns the repair schedule identified by id.
+func (c *Client) GetSchedule(ctx context.Context, id string) (RepairSchedule, error) {
+	const op = "GetSchedule"
+
+	var res RepairSchedule
+	err := c.do(ctx, op, "GET", "/repair_schedule/"+id, nil, http.StatusOK, &res)
+
+	return res, err
+}
+
+// AddScheduleParams are the parameters used to create a new repair schedule.
+type AddScheduleParams struct {
+	Cluster  string
+	Keyspace string
+	Tables   []string
+	Owner    string
+	// Segments and SegmentsPerNode are optional, Reaper uses its own default if both are zero.
+	Segments            int
+	SegmentsPerNode     int
+	Parallelism         Parallelism
+	Intensity           float64
+	Incremental         bool
+	ScheduleDaysBetween int
+	// ScheduleTriggerTime is optional, Reaper uses the next midnight if empty.
+	ScheduleTriggerTime string
+}
+
+func (p AddScheduleParams) values() url.Values {
+	qry := make(url.Values)
+	qry.Add("clusterName", p.Cluster)
+	qry.Add("keyspace", p.Keyspace)
+	if len(p.Tables) > 0 {
+		qry.Add("tables", strings.Join(p.Tables, ","))
+	}
+	qry.Add("owner", p.Owner)
+	if p.Segments > 0 {
+		qry.Add("segmentCount", strconv.Itoa(p.Segments))
+	}
+	if p.SegmentsPerNode > 0 {
+		qry.Add("segmentCountPerNode", strconv.Itoa(p.SegmentsPerNode))
+	}
+	qry.Add("repairParallelism", p.Parallelism.String())
+	qry.Add("intensity", fmt.Sprintf("%0.3f", p.Intensity))
+	qry.Add("incrementalRepair", fmt.Sprintf("%v", p.Incremental))
+	qry.Add("scheduleDaysBetween", strconv.Itoa(p.ScheduleDaysBetween))
+	if p.ScheduleTriggerTime != "" {
+		qry.Add("scheduleTriggerTime", p.ScheduleTriggerTime)
+	}
+
+	return qry
+}
+
+// AddSchedule creates a new repair schedule.
+func (c *Client) AddSchedule(ctx context.Context, params AddScheduleParams) (RepairSchedule, error) {
+	const op = "AddSchedule"
+
+	var res RepairSchedule
+	err := c.do(ctx, op, "POST", "/repair_schedule", params.values(), http.StatusCreated, &res)
+
+	return res, err
+}
+
+// ChangeScheduleState changes the state of the repair schedule identified by id.
+func (c *Client) ChangeScheduleState(ctx context.Context, id string, state ScheduleState) (RepairSchedule, error) {
+	const op = "ChangeScheduleState"
+
+	qry := make(url.Values)
+	qry.Add("state", state.String())
+
+	var res RepairSchedule
+	err := c.do(ctx, op, "PUT", "/repair_schedule/"+id, qry, http.StatusOK, &res)
+
+	return res, err
+}
+
+// UpdateScheduleParams are the parameters of a repair schedule which can be changed.
+// The zero values are left unchanged.
+type UpdateScheduleParams struct {
+	Owner               string      `json:"owner,omitempty"`
+	Parallelism         Parallelism `json:"repair_parallelism,omitempty"`
+	Intensity           float64     `json:"intensity,omitempty"`
+	ScheduleDaysBetween int         `json:"scheduled_days_between,omitempty"`
+}
+
+// UpdateSchedule changes the parameters of the repair schedule identified by id.
+func (c *Client) UpdateSchedule(ctx context.Context, id string, params UpdateScheduleParams) (RepairSchedule, error) {
+	const op = "UpdateSchedule"
+
+	pl, err := jsonPayload(params)
+	if err != nil {
+		return RepairSchedule{}, errors.E(errors.Invalid, op, err)
+	}
+
+	var res RepairSchedule
+	err = c.doWithPayload(ctx, op, "PATCH", "/repair_schedule/"+id, nil, pl, http.StatusOK, &res)
+
+	return res, err
+}
+
+// DeleteSchedule deletes the repair schedule identified by id.
+// owner must be the owner of the schedule.
+func (c *Client) DeleteSchedule(ctx context.Context, id, owner string) (RepairSchedule, error) {
+	const op = "DeleteSchedule"
+
+	qry := make(url.Values)
+	qry.Add("owner", owner)
+
+	var res RepairSchedule
+	err := c.do(ctx, op, "DELETE", "/repair_schedule/"+id, qry, http.StatusOK, &res)
+
+	return res, err
+}
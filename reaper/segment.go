@@ -0,0 +1,191 @@
+package reaper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vrischmann/happyreaper/errors"
+)
+
+type SegmentState string
+
+const (
+	SegNotStarted SegmentState = "NOT_STARTED"
+	SegStarted    SegmentState = "STARTED"
+	SegRunning    SegmentState = "RUNNING"
+	SegDone       SegmentState = "DONE"
+)
+
+func (s SegmentState) String() string { return string(s) }
+
+func (s *SegmentState) Set(str string) error {
+	switch {
+	case strings.EqualFold(str, "not_started"):
+		*s = SegNotStarted
+	case strings.EqualFold(str, "started"):
+		*s = SegStarted
+	case strings.EqualFold(str, "running"):
+		*s = SegRunning
+	case strings.EqualFold(str, "done"):
+		*s = SegDone
+	default:
+		return errors.Errorf("invalid segment state %q", str)
+	}
+	return nil
+}
+
+// TokenRange is a range of tokens, the tokens are kept as strings since they don't fit in an int64 with all partitioners.
+type TokenRange struct {
+	Start string `json:"start" yaml:"start"`
+	End   string `json:"end" yaml:"end"`
+}
+
+// Segment is a token range repaired as one unit by a repair run.
+type Segment struct {
+	ID    string `json:"id" yaml:"id"`
+	RunID string `json:"run_id" yaml:"run_id"`
+
+	TokenRange      TokenRange   `json:"token_range" yaml:"token_range"`
+	State           SegmentState `json:"state" yaml:"state"`
+	CoordinatorHost string       `json:"coordinator_host" yaml:"coordinator_host"`
+	FailCount       int          `json:"fail_count" yaml:"fail_count"`
+
+	StartTime *time.Time `json:"start_time" yaml:"start_time"`
+	EndTime   *time.Time `json:"end_time" yaml:"end_time"`
+}
+
+func (s Segment) String() string {
+	return fmt.Sprintf("{id:%s run:%s range:(%s,%s] state:%s coordinator:%q fails:%d start:%s end:%s}",
+		s.ID, s.RunID,
+		s.TokenRange.Start, s.TokenRange.End,
+		s.State, s.CoordinatorHost, s.FailCount,
+		s.StartTime, s.EndTime,
+	)
+}
+
+func (s Segment) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if f.Flag('+') {
+			fmt.Fprintf(f, "%-20s %s\n", "id:", s.ID)
+			fmt.Fprintf(f, "%-20s %s\n", "run id:", s.RunID)
+			fmt.Fprintf(f, "%-20s (%s,%s]\n", "token range:", s.TokenRange.Start, s.TokenRange.End)
+			fmt.Fprintf(f, "%-20s %s\n", "state:", s.State)
+			fmt.Fprintf(f, "%-20s %s\n", "coordinator host:", s.CoordinatorHost)
+			fmt.Fprintf(f, "%-20s %d\n", "fail count:", s.FailCount)
+			fmt.Fprintf(f, "%-20s %s\n", "start time:", s.StartTime)
+			fmt.Fprintf(f, "%-20s %s\n", "end time:", s.EndTime)
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(f, s.String())
+	}
+}
+
+// segmentJSON is a segment as sent by Reaper.
+//
+// Older Reaper versions put the tokens directly in the token range while newer ones put them in a base range,
+// and the times are either milliseconds since the epoch or strings depending on the version.
+type segmentJSON struct {
+	ID         string `json:"id"`
+	RunID      string `json:"runId"`
+	TokenRange struct {
+		Start     json.Number `json:"start"`
+		End       json.Number `json:"end"`
+		BaseRange *struct {
+			Start json.Number `json:"start"`
+			End   json.Number `json:"end"`
+		} `json:"baseRange"`
+	} `json:"tokenRange"`
+	State           SegmentState    `json:"state"`
+	CoordinatorHost string          `json:"coordinatorHost"`
+	FailCount       int             `json:"failCount"`
+	StartTime       json.RawMessage `json:"startTime"`
+	EndTime         json.RawMessage `json:"endTime"`
+}
+
+func (s *Segment) UnmarshalJSON(data []byte) error {
+	var raw segmentJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	startTime, err := parseTime(raw.StartTime)
+	if err != nil {
+		return err
+	}
+	endTime, err := parseTime(raw.EndTime)
+	if err != nil {
+		return err
+	}
+
+	*s = Segment{
+		ID:              raw.ID,
+		RunID:           raw.RunID,
+		TokenRange:      TokenRange{Start: raw.TokenRange.Start.String(), End: raw.TokenRange.End.String()},
+		State:           raw.State,
+		CoordinatorHost: raw.CoordinatorHost,
+		FailCount:       raw.FailCount,
+		StartTime:       startTime,
+		EndTime:         endTime,
+	}
+	if br := raw.TokenRange.BaseRange; br != nil {
+		s.TokenRange = TokenRange{Start: br.Start.String(), End: br.End.String()}
+	}
+
+	return nil
+}
+
+// parseTime parses a time sent either as milliseconds since the epoch or as a RFC 3339 string.
+func parseTime(raw json.RawMessage) (*time.Time, error) {
+	s := string(raw)
+	if s == "" || s == "null" {
+		return nil, nil
+	}
+
+	if s[0] == '"' {
+		var t time.Time
+		if err := json.Unmarshal(raw, &t); err != nil {
+			return nil, err
+		}
+		return &t, nil
+	}
+
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, errors.Errorf("invalid time %s", s)
+	}
+	t := time.Unix(0, ms*int64(time.Millisecond))
+
+	return &t, nil
+}
+
+// ListSegments returns the segments of the repair run identified by runID.
+func (c *Client) ListSegments(ctx context.Context, runID string) ([]Segment, error) {
+	const op = "ListSegments"
+
+	var res []Segment
+	err := c.do(ctx, op, "GET", "/repair_run/"+runID+"/segments", nil, http.StatusOK, &res)
+
+	return res, err
+}
+
+// AbortSegment aborts the segment identified by segmentID of the repair run identified by runID.
+//
+// Reaper puts the segment back in the NOT_STARTED state so that it is repaired again later,
+// this is also true for a segment already DONE.
+func (c *Client) AbortSegment(ctx context.Context, runID, segmentID string) (Segment, error) {
+	const op = "AbortSegment"
+
+	var res Segment
+	err := c.do(ctx, op, "POST", "/repair_run/"+runID+"/segments/abort/"+segmentID, nil, http.StatusOK, &res)
+
+	return res, err
+}
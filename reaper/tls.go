@@ -0,0 +1,62 @@
+package reaper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+
+	"github.com/vrischmann/happyreaper/errors"
+)
+
+// TLSOptions describes how to connect to a Reaper instance behind TLS.
+type TLSOptions struct {
+	// CAFile is a PEM bundle of certificate authorities used to verify the server.
+	// If empty the system pool is used.
+	CAFile string `json:"ca_file" yaml:"ca_file"`
+	// CertFile and KeyFile are the client certificate and key, both are needed for mutual TLS.
+	CertFile string `json:"cert_file" yaml:"cert_file"`
+	KeyFile  string `json:"key_file" yaml:"key_file"`
+	// InsecureSkipVerify disables the verification of the server certificate.
+	InsecureSkipVerify bool `json:"insecure_skip_verify" yaml:"insecure_skip_verify"`
+}
+
+// IsZero returns true if no option is set.
+func (o TLSOptions) IsZero() bool {
+	return o == TLSOptions{}
+}
+
+// Config builds a tls.Config from the options.
+func (o TLSOptions) Config() (*tls.Config, error) {
+	const op = "TLSOptions.Config"
+
+	conf := &tls.Config{
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	if o.CAFile != "" {
+		data, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, errors.E(errors.IO, op, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.E(errors.Invalid, op, errors.Errorf("no certificate found in %q", o.CAFile))
+		}
+		conf.RootCAs = pool
+	}
+
+	switch {
+	case o.CertFile != "" && o.KeyFile != "":
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, errors.E(errors.Invalid, op, err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+
+	case o.CertFile != "" || o.KeyFile != "":
+		return nil, errors.E(errors.Invalid, op, errors.Str("both the client certificate and key are needed"))
+	}
+
+	return conf, nil
+}
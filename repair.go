@@ -1,143 +1,104 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
-	"net/http"
-	"net/url"
-	"strconv"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/vrischmann/flagutil"
 	"github.com/vrischmann/happyreaper/errors"
+	"github.com/vrischmann/happyreaper/reaper"
 )
 
-type RunState string
-
-const (
-	NotStarted RunState = "NOT_STARTED"
-	Running    RunState = "RUNNING"
-	Error      RunState = "ERROR"
-	Done       RunState = "DONE"
-	Paused     RunState = "PAUSED"
-	Aborted    RunState = "ABORTED"
-	Deleted    RunState = "DELETED"
-)
+func listRepairs(args []string) error {
+	var (
+		fs        = flag.NewFlagSet("list-repairs", flag.ContinueOnError)
+		filter    = newRepairFilter(fs)
+		flTable   = fs.Bool("table", false, "Print a table with one run per row")
+		flColumns flagutil.Strings
+		flColor   = fs.Bool("color", true, "Color the table rows by state")
+	)
 
-func (s RunState) String() string { return string(s) }
+	fs.Var(&flColumns, "columns", "The columns to show in the table (comma separated list of fields, plus progress)")
 
-func (s *RunState) Set(str string) error {
+	err := fs.Parse(args)
 	switch {
-	case strings.EqualFold(str, "not_started"):
-		*s = NotStarted
-	case strings.EqualFold(str, "running"):
-		*s = Running
-	case strings.EqualFold(str, "error"):
-		*s = Error
-	case strings.EqualFold(str, "done"):
-		*s = Done
-	case strings.EqualFold(str, "paused"):
-		*s = Paused
-	case strings.EqualFold(str, "aborted"):
-		*s = Aborted
-	case strings.EqualFold(str, "deleted"):
-		*s = Deleted
-	default:
-		return errors.Errorf("invalid state %q", str)
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
 	}
-	return nil
-}
 
-type RepairRun struct {
-	ID    string `json:"id"`
-	Owner string `json:"owner"`
+	runs, err := filter.list()
+	if err != nil {
+		return err
+	}
 
-	ClusterName  string `json:"cluster_name"`
-	KeyspaceName string `json:"keyspace_name"`
+	if flOutput != OutputText {
+		return writeOutput(os.Stdout, flOutput, runs)
+	}
 
-	State RunState `json:"state"`
+	if *flTable {
+		return printRunsTable(os.Stdout, runs, flColumns, *flColor)
+	}
 
-	Cause            string   `json:"cause"`
-	ColumnFamilies   []string `json:"column_families"`
-	Intensity        float64  `json:"intensity"`
-	TotalSegments    int      `json:"total_segments"`
-	SegmentsRepaired int      `json:"segments_repaired"`
-	LastEvent        string   `json:"last_event"`
-	Duration         string   `json:"duration"`
+	for _, run := range runs {
+		fmt.Printf("%+v\n", run)
+	}
 
-	CreationTime *time.Time `json:"creation_time"`
-	StartTime    *time.Time `json:"start_time"`
-	EndTime      *time.Time `json:"end_time"`
-	PauseTime    *time.Time `json:"pause_time"`
+	return nil
 }
 
-func (r RepairRun) String() string {
-	s := fmt.Sprintf("{id:%s owner:%q cluster:%q keyspace:%q state:%s cause:%q cf:%v intensity:%0.3f segments:%d repaired:%d lastEvent:%q duration:%q creation:%s start:%s end:%s pause:%d}",
-		r.ID, r.Owner,
-		r.ClusterName, r.KeyspaceName,
-		r.State, r.Cause,
-		r.ColumnFamilies, r.Intensity,
-		r.TotalSegments, r.SegmentsRepaired,
-		r.LastEvent, r.Duration,
-		r.CreationTime, r.StartTime,
-		r.EndTime, r.PauseTime,
+func viewRepair(args []string) error {
+	var (
+		fs   = flag.NewFlagSet("view-repair", flag.ContinueOnError)
+		flID = fs.String("id", "", "The repair ID")
 	)
-	return s
-}
 
-func (r RepairRun) Format(s fmt.State, verb rune) {
-	switch verb {
-	case 'v':
-		if s.Flag('+') {
-			fmt.Fprintf(s, "%-20s %s\n", "id:", r.ID)
-			fmt.Fprintf(s, "%-20s %s\n", "owner:", r.Owner)
-			fmt.Fprintf(s, "%-20s %s\n", "cluster name:", r.ClusterName)
-			fmt.Fprintf(s, "%-20s %s\n", "keyspace name:", r.KeyspaceName)
-			fmt.Fprintf(s, "%-20s %s\n", "state:", r.State)
-			fmt.Fprintf(s, "%-20s %s\n", "cause:", r.Cause)
-			fmt.Fprintf(s, "%-20s %v\n", "column families:", r.ColumnFamilies)
-			fmt.Fprintf(s, "%-20s %0.3f\n", "intensity:", r.Intensity)
-			fmt.Fprintf(s, "%-20s %d\n", "total segments:", r.TotalSegments)
-			fmt.Fprintf(s, "%-20s %d\n", "segments repaired:", r.SegmentsRepaired)
-			fmt.Fprintf(s, "%-20s %s\n", "last event:", r.LastEvent)
-			fmt.Fprintf(s, "%-20s %s\n", "duration:", r.Duration)
-			fmt.Fprintf(s, "%-20s %s\n", "creation time:", r.CreationTime)
-			fmt.Fprintf(s, "%-20s %s\n", "start time:", r.StartTime)
-			fmt.Fprintf(s, "%-20s %s\n", "end time:", r.EndTime)
-			fmt.Fprintf(s, "%-20s %s\n", "pause time:", r.PauseTime)
-			return
-		}
-		fallthrough
-	case 's':
-		io.WriteString(s, r.String())
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if *flID == "" {
+		return errors.Str("please provide a valid ID")
 	}
+
+	res, err := client.GetRepairRun(ctx, *flID)
+	if err != nil {
+		return err
+	}
+
+	if flOutput != OutputText {
+		return writeOutput(os.Stdout, flOutput, res)
+	}
+
+	fmt.Printf("%+v\n", res)
+
+	return nil
 }
 
-func listRepairs(args []string) error {
-	const op = "listRepairs"
+// Exit status codes of watch-repair when the run ends in something other than DONE.
+const (
+	exitRunError   = 2
+	exitRunAborted = 3
+	exitRunDeleted = 4
+)
 
+func watchRepair(args []string) error {
 	var (
-		fs            = flag.NewFlagSet("list-repairs", flag.ContinueOnError)
-		flRunState    RunState
-		flCluster     = fs.String("cluster", "", "Filter by cluster")
-		flKeyspace    = fs.String("keyspace", "", "Filter by keyspace")
-		flTables      flagutil.Strings
-		flOwner       = fs.String("owner", "", "Filter by owner")
-		flCause       = fs.String("cause", "", "Filter by cause")
-		flStartAfter  myTime
-		flStartBefore myTime
+		fs         = flag.NewFlagSet("watch-repair", flag.ContinueOnError)
+		flID       = fs.String("id", "", "The repair ID")
+		flInterval = fs.Duration("interval", 10*time.Second, "The polling interval")
 	)
 
-	fs.Var(&flRunState, "run-state", "Filter by run state")
-	fs.Var(&flTables, "tables", "Filter by tables (comma separated list of tables)")
-	fs.Var(&flStartAfter, "start-after", "Filter by runs that start after this date")
-	fs.Var(&flStartBefore, "start-before", "Filter by runs that start before this date")
-
 	err := fs.Parse(args)
 	switch {
 	case err == flag.ErrHelp:
@@ -146,71 +107,172 @@ func listRepairs(args []string) error {
 		return err
 	}
 
-	qry := make(url.Values)
-	if flRunState != "" {
-		qry.Add("state", flRunState.String())
+	if *flID == "" {
+		return errors.Str("please provide a valid ID")
 	}
 
-	resp, err := http.Get(makeURL("/repair_run?") + qry.Encode())
-	if err != nil {
-		return errors.E(errors.IO, op, err)
+	var drawn bool
+	for {
+		run, err := client.GetRepairRun(ctx, *flID)
+		if err != nil {
+			return err
+		}
+
+		if drawn {
+			// Move the cursor up to redraw over the previous progress.
+			fmt.Print("\033[2A")
+		}
+		printRepairProgress(os.Stdout, run, time.Now())
+		drawn = true
+
+		var code int
+		switch run.State {
+		case reaper.Done:
+			return nil
+		case reaper.Error:
+			code = exitRunError
+		case reaper.Aborted:
+			code = exitRunAborted
+		case reaper.Deleted:
+			code = exitRunDeleted
+		default:
+			select {
+			case <-time.After(*flInterval):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+
+		return &exitStatusError{
+			code: code,
+			err:  errors.Errorf("repair run %s ended in state %s", run.ID, run.State),
+		}
 	}
-	defer resp.Body.Close()
+}
 
-	var buf bytes.Buffer
-	rd := io.TeeReader(resp.Body, &buf)
+// progressBar draws the progress of run with width characters.
+func progressBar(run reaper.RepairRun, width int) string {
+	var ratio float64
+	if run.TotalSegments > 0 {
+		ratio = float64(run.SegmentsRepaired) / float64(run.TotalSegments)
+	}
+	filled := int(ratio * float64(width))
+	if filled > width {
+		filled = width
+	}
+
+	return strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+}
+
+func printRepairProgress(w io.Writer, run reaper.RepairRun, now time.Time) {
+	bar := progressBar(run, 40)
 
-	if resp.StatusCode != http.StatusOK {
-		io.Copy(&buf, rd)
-		return errors.Str(buf.String())
+	eta := "unknown"
+	if remaining, ok := estimateRemaining(run, now); ok {
+		eta = remaining.String()
 	}
 
-	var res []RepairRun
-	dec := json.NewDecoder(rd)
+	// \033[2K clears the line before writing to it.
+	fmt.Fprintf(w, "\033[2K[%s] %s (%d/%d) %s, remaining: %s\n",
+		bar, formatProgress(run.SegmentsRepaired, run.TotalSegments),
+		run.SegmentsRepaired, run.TotalSegments,
+		run.State, eta,
+	)
+	fmt.Fprintf(w, "\033[2Klast event: %s\n", run.LastEvent)
+}
 
-	if err := dec.Decode(&res); err != nil {
-		return errors.E(errors.IO, op, err)
+// estimateRemaining extrapolates the time needed to repair the remaining segments
+// from the average time spent per segment since the run started.
+func estimateRemaining(run reaper.RepairRun, now time.Time) (time.Duration, bool) {
+	switch {
+	case run.State == reaper.Done:
+		return 0, true
+	case run.StartTime == nil || run.SegmentsRepaired <= 0:
+		return 0, false
 	}
 
-	for _, run := range res {
-		switch {
-		case *flCluster != "" && *flCluster != run.ClusterName:
-			continue
+	elapsed := now.Sub(*run.StartTime)
+	perSegment := elapsed / time.Duration(run.SegmentsRepaired)
+	remaining := perSegment * time.Duration(run.TotalSegments-run.SegmentsRepaired)
 
-		case *flKeyspace != "" && *flKeyspace != run.KeyspaceName:
-			continue
+	return remaining.Truncate(time.Second), true
+}
 
-		case len(flTables) > 0 && !contains(run.ColumnFamilies, flTables):
-			continue
+func changeRepairState(repairID string, state reaper.RunState) error {
+	res, err := client.ChangeRepairRunState(ctx, repairID, state)
+	if err != nil {
+		return err
+	}
 
-		case *flOwner != "" && *flOwner != run.Owner:
-			continue
+	color.Yellow("State changed to %s", state)
 
-		case *flCause != "" && *flCause != run.Cause:
-			continue
+	if res.ID != "" {
+		fmt.Printf("%+v\n", res)
+	}
 
-		case (!flStartAfter.IsZero() || !flStartBefore.IsZero()) && run.StartTime == nil:
-			continue
+	return nil
+}
 
-		case !flStartAfter.IsZero() && run.StartTime.Before(flStartAfter.Time):
-			continue
+// changeSegment aborts a segment of a running or paused repair run, the segment must be in one of the states given.
+// Reaper then repairs the segment again.
+func changeSegment(runID, segmentID string, states ...reaper.SegmentState) error {
+	run, err := client.GetRepairRun(ctx, runID)
+	if err != nil {
+		return err
+	}
+	if run.State != reaper.Running && run.State != reaper.Paused {
+		return errors.E(errors.Conflict, "changeSegment", errors.Errorf("repair %s is %s, it must be RUNNING or PAUSED", runID, run.State))
+	}
 
-		case !flStartBefore.IsZero() && run.StartTime.After(flStartBefore.Time):
-			continue
+	segments, err := client.ListSegments(ctx, runID)
+	if err != nil {
+		return err
+	}
+
+	var seg *reaper.Segment
+	for i := range segments {
+		if segments[i].ID == segmentID {
+			seg = &segments[i]
+			break
 		}
+	}
 
-		fmt.Printf("%+v\n", run)
+	switch {
+	case seg == nil:
+		return errors.E(errors.NotFound, "changeSegment", errors.Errorf("segment %s not found in repair %s", segmentID, runID))
+	case !containsSegmentState(states, seg.State):
+		return errors.E(errors.Conflict, "changeSegment", errors.Errorf("segment %s is %s, it must be one of %v", segmentID, seg.State, states))
+	}
+
+	res, err := client.AbortSegment(ctx, runID, segmentID)
+	if err != nil {
+		return err
+	}
+
+	color.Yellow("Segment %s put back in NOT_STARTED, it will be repaired again", segmentID)
+
+	if res.ID != "" {
+		fmt.Printf("%+v\n", res)
 	}
 
 	return nil
 }
 
-func viewRepair(args []string) error {
-	const op = "viewRepair"
+func containsSegmentState(states []reaper.SegmentState, state reaper.SegmentState) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
 
+func abortSegment(args []string) error {
 	var (
-		fs   = flag.NewFlagSet("view-repair", flag.ContinueOnError)
-		flID = fs.String("id", "", "The repair ID")
+		fs          = flag.NewFlagSet("abort-segment", flag.ContinueOnError)
+		flID        = fs.String("id", "", "The repair ID")
+		flSegmentID = fs.String("segment", "", "The segment ID, it must be STARTED or RUNNING")
 	)
 
 	err := fs.Parse(args)
@@ -221,76 +283,171 @@ func viewRepair(args []string) error {
 		return err
 	}
 
-	if *flID == "" {
+	switch {
+	case *flID == "":
 		return errors.Str("please provide a valid ID")
+	case *flSegmentID == "":
+		return errors.Str("please provide a valid segment ID")
 	}
 
-	resp, err := http.Get(makeURL("/repair_run/" + *flID))
-	if err != nil {
-		return errors.E(errors.IO, op, err)
-	}
-	defer resp.Body.Close()
+	return changeSegment(*flID, *flSegmentID, reaper.SegStarted, reaper.SegRunning)
+}
 
-	var buf bytes.Buffer
-	rd := io.TeeReader(resp.Body, &buf)
+// resetSegment repairs a DONE segment again. Reaper has no dedicated endpoint for it,
+// the segment is aborted like with abort-segment which puts it back in NOT_STARTED.
+func resetSegment(args []string) error {
+	var (
+		fs          = flag.NewFlagSet("reset-segment", flag.ContinueOnError)
+		flID        = fs.String("id", "", "The repair ID")
+		flSegmentID = fs.String("segment", "", "The segment ID, it must be DONE and is aborted to be repaired again")
+	)
 
-	if resp.StatusCode != http.StatusOK {
-		io.Copy(&buf, rd)
-		return errors.Str(buf.String())
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
 	}
 
-	var res RepairRun
-	dec := json.NewDecoder(rd)
-
-	if err := dec.Decode(&res); err != nil {
-		return errors.E(errors.IO, op, err)
+	switch {
+	case *flID == "":
+		return errors.Str("please provide a valid ID")
+	case *flSegmentID == "":
+		return errors.Str("please provide a valid segment ID")
 	}
 
-	fmt.Printf("%+v\n", res)
-
-	return nil
+	return changeSegment(*flID, *flSegmentID, reaper.SegDone)
 }
 
-func changeRepairState(repairID string, state RunState) error {
-	const op = "changeRepairState"
+func updateRepair(args []string) error {
+	var (
+		fs          = flag.NewFlagSet("update-repair", flag.ContinueOnError)
+		flID        = fs.String("id", "", "The repair ID")
+		flIntensity = fs.Float64("intensity", 0, "The new intensity, only for a PAUSED or NOT_STARTED repair")
+		flSegments  = fs.Int("segments", 0, "The new number of segments, only for a NOT_STARTED repair: the repair is replaced by a new one, with a new ID")
+	)
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
 
-	qry := make(url.Values)
-	qry.Add("state", state.String())
+	switch {
+	case *flID == "":
+		return errors.Str("please provide a valid ID")
+	case *flIntensity == 0 && *flSegments == 0:
+		return errors.Str("please provide an intensity or a number of segments")
+	case *flIntensity < 0 || *flIntensity > 1:
+		return errors.Str("the intensity must be between 0 and 1")
+	case *flSegments < 0:
+		return errors.Str("the number of segments must be positive")
+	}
 
-	ur := makeURL("/repair_run/"+repairID) + "?" + qry.Encode()
+	const op = "updateRepair"
 
-	req, err := http.NewRequest("PUT", ur, nil)
+	before, err := client.GetRepairRun(ctx, *flID)
 	if err != nil {
-		return errors.E(errors.Invalid, op, err)
+		return err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	switch {
+	case *flSegments > 0 && before.State != reaper.NotStarted:
+		return errors.E(errors.Conflict, op, errors.Errorf("repair %s is %s, the number of segments can only be changed while NOT_STARTED", before.ID, before.State))
+	case *flIntensity > 0 && before.State != reaper.NotStarted && before.State != reaper.Paused:
+		return errors.E(errors.Conflict, op, errors.Errorf("repair %s is %s, the intensity can only be changed while PAUSED or NOT_STARTED", before.ID, before.State))
+	}
+
+	var after reaper.RepairRun
+	if *flSegments > 0 {
+		after, err = recreateRepair(before, *flSegments, *flIntensity)
+	} else {
+		_, err = client.ChangeRepairRunIntensity(ctx, before.ID, *flIntensity)
+		if err == nil {
+			after, err = client.GetRepairRun(ctx, before.ID)
+		}
+	}
 	if err != nil {
-		return errors.E(errors.IO, op, err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	var buf bytes.Buffer
-	rd := io.TeeReader(resp.Body, &buf)
+	replaced := after.ID != before.ID
 
-	if resp.StatusCode != http.StatusOK {
-		io.Copy(&buf, rd)
-		return errors.Str(buf.String())
+	if flOutput != OutputText {
+		if replaced {
+			return writeOutput(os.Stdout, flOutput, repairReplacement{OldID: before.ID, NewID: after.ID, Run: after})
+		}
+		return writeOutput(os.Stdout, flOutput, after)
 	}
 
-	color.Yellow("State changed to %s", state)
+	color.Yellow("Repair %s correctly updated", after.ID)
+
+	printChanges(os.Stdout, runFieldNames(), runRecord(before), runRecord(after))
 
-	if buf.Len() > 0 {
-		fmt.Println(buf.String())
+	if replaced {
+		color.New(color.FgRed, color.Bold).Printf("\nThe repair was replaced, its ID changed: %s -> %s\n", before.ID, after.ID)
 	}
 
 	return nil
 }
 
+// repairReplacement is written by update-repair when the repair was replaced by a new one.
+type repairReplacement struct {
+	OldID string           `json:"old_id" yaml:"old_id"`
+	NewID string           `json:"new_id" yaml:"new_id"`
+	Run   reaper.RepairRun `json:"run" yaml:"run"`
+}
+
+// recreateRepair replaces a NOT_STARTED repair run by a new one with the same parameters
+// except for the number of segments and optionally the intensity.
+//
+// Reaper can't change the number of segments of an existing run, since it was not started nothing is lost
+// but the new run has a new ID.
+func recreateRepair(run reaper.RepairRun, segments int, intensity float64) (reaper.RepairRun, error) {
+	if intensity == 0 {
+		intensity = run.Intensity
+	}
+	par := run.RepairParallelism
+	if par == "" {
+		par = reaper.Sequential
+	}
+
+	params := reaper.AddRepairRunParams{
+		Cluster:           run.ClusterName,
+		Keyspace:          run.KeyspaceName,
+		Tables:            run.ColumnFamilies,
+		Owner:             run.Owner,
+		Cause:             run.Cause,
+		Segments:          segments,
+		Parallelism:       par,
+		Intensity:         intensity,
+		Incremental:       run.IncrementalRepair,
+		Nodes:             run.Nodes,
+		Datacenters:       run.Datacenters,
+		BlacklistedTables: run.BlacklistedTables,
+	}
+
+	res, err := client.AddRepairRun(ctx, params)
+	if err != nil {
+		return reaper.RepairRun{}, err
+	}
+
+	if _, err := client.DeleteRepairRun(ctx, run.ID, run.Owner); err != nil {
+		return reaper.RepairRun{}, errors.Errorf("repair %s created but unable to delete repair %s, delete it yourself. err=%v", res.ID, run.ID, err)
+	}
+
+	return res, nil
+}
+
 func pauseRepair(args []string) error {
 	var (
-		fs   = flag.NewFlagSet("pause-repair", flag.ContinueOnError)
-		flID = fs.String("id", "", "The repair ID")
+		fs     = flag.NewFlagSet("pause-repair", flag.ContinueOnError)
+		flID   = fs.String("id", "", "The repair ID, otherwise all the repairs matching the filters are paused")
+		filter = newRepairFilter(fs)
+		opts   = newBulkOptions(fs)
 	)
 
 	err := fs.Parse(args)
@@ -301,17 +458,29 @@ func pauseRepair(args []string) error {
 		return err
 	}
 
-	if *flID == "" {
-		return errors.Str("please provide a valid ID")
+	switch {
+	case *flID != "":
+		return changeRepairState(*flID, reaper.Paused)
+	case filter.isZero():
+		return errors.Str("please provide a valid ID or at least one filter")
+	}
+
+	if filter.runState == "" {
+		filter.runState = reaper.Running
 	}
 
-	return changeRepairState(*flID, Paused)
+	return bulkRepairs("Pause", filter, opts, func(run reaper.RepairRun) error {
+		_, err := client.ChangeRepairRunState(ctx, run.ID, reaper.Paused)
+		return err
+	})
 }
 
 func resumeRepair(args []string) error {
 	var (
-		fs   = flag.NewFlagSet("resume-repair", flag.ContinueOnError)
-		flID = fs.String("id", "", "The repair ID")
+		fs     = flag.NewFlagSet("resume-repair", flag.ContinueOnError)
+		flID   = fs.String("id", "", "The repair ID, otherwise all the repairs matching the filters are resumed")
+		filter = newRepairFilter(fs)
+		opts   = newBulkOptions(fs)
 	)
 
 	err := fs.Parse(args)
@@ -322,20 +491,29 @@ func resumeRepair(args []string) error {
 		return err
 	}
 
-	if *flID == "" {
-		return errors.Str("please provide a valid ID")
+	switch {
+	case *flID != "":
+		return changeRepairState(*flID, reaper.Running)
+	case filter.isZero():
+		return errors.Str("please provide a valid ID or at least one filter")
+	}
+
+	if filter.runState == "" {
+		filter.runState = reaper.Paused
 	}
 
-	return changeRepairState(*flID, Running)
+	return bulkRepairs("Resume", filter, opts, func(run reaper.RepairRun) error {
+		_, err := client.ChangeRepairRunState(ctx, run.ID, reaper.Running)
+		return err
+	})
 }
 
 func deleteRepair(args []string) error {
-	const op = "deleteRepair"
-
 	var (
-		fs      = flag.NewFlagSet("delete-repair", flag.ContinueOnError)
-		flID    = fs.String("id", "", "The repair ID")
-		flOwner = fs.String("owner", "", "The owner")
+		fs     = flag.NewFlagSet("delete-repair", flag.ContinueOnError)
+		flID   = fs.String("id", "", "The repair ID, otherwise all the repairs matching the filters are deleted")
+		filter = newRepairFilter(fs)
+		opts   = newBulkOptions(fs)
 	)
 
 	err := fs.Parse(args)
@@ -347,54 +525,50 @@ func deleteRepair(args []string) error {
 	}
 
 	if *flID == "" {
-		return errors.Str("please provide a valid ID")
-	}
-	if *flOwner == "" {
-		return errors.Str("please provide a valid owner")
-	}
-
-	qry := make(url.Values)
-	qry.Add("owner", *flOwner)
+		if filter.isZero() {
+			return errors.Str("please provide a valid ID or at least one filter")
+		}
 
-	ur := makeURL("/repair_run/" + *flID + "?" + qry.Encode())
+		return bulkRepairs("Delete", filter, opts, func(run reaper.RepairRun) error {
+			_, err := client.DeleteRepairRun(ctx, run.ID, run.Owner)
+			return err
+		})
+	}
 
-	req, err := http.NewRequest("DELETE", ur, nil)
-	if err != nil {
-		return errors.E(errors.Invalid, op, err)
+	// With an ID the owner is required, Reaper checks it matches the owner of the run.
+	owner := *filter.owner
+	if owner == "" {
+		owner = defaults.Owner
+	}
+	if owner == "" {
+		return errors.Str("please provide a valid owner")
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	res, err := client.DeleteRepairRun(ctx, *flID, owner)
 	if err != nil {
-		return errors.E(errors.IO, op, err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	var buf bytes.Buffer
-	rd := io.TeeReader(resp.Body, &buf)
+	color.Yellow("Repair %s correctly deleted", *flID)
 
-	if resp.StatusCode != http.StatusOK {
-		io.Copy(&buf, rd)
-		return errors.Str(buf.String())
+	if res.ID != "" {
+		fmt.Printf("%+v\n", res)
 	}
 
-	fmt.Println(buf.String())
-
 	return nil
 }
 
 func addRepair(args []string) error {
-	const op = "addRepair"
-
 	var (
 		fs                  = flag.NewFlagSet("add-repair", flag.ContinueOnError)
 		flCluster           = fs.String("cluster", "", "The cluster name")
 		flKeyspace          = fs.String("keyspace", "", "The keyspace name")
 		flTables            flagutil.Strings
-		flOwner             = fs.String("owner", "", "The owner")
+		flOwner             = fs.String("owner", defaults.Owner, "The owner")
 		flCause             = fs.String("cause", "", "The cause for the repair")
-		flSegments          = fs.Int("segments", 200, "The number of segments")
-		flPar               Parallelism
-		flIntensity         = fs.Float64("intensity", 0.5, "The intensity")
+		flSegments          = fs.Int("segments", defaults.Segments, "The number of segments")
+		flPar               reaper.Parallelism
+		flIntensity         = fs.Float64("intensity", defaults.Intensity, "The intensity")
 		flIncremental       = fs.Bool("inc", false, "Incremental repair or not")
 		flNodes             flagutil.Strings
 		flDatacenters       flagutil.Strings
@@ -429,46 +603,27 @@ func addRepair(args []string) error {
 	}
 
 	if flPar == "" {
-		flPar = Sequential
+		flPar = reaper.Sequential
 	}
 
-	qry := make(url.Values)
-	qry.Add("clusterName", *flCluster)
-	qry.Add("keyspace", *flKeyspace)
-	if len(flTables) > 0 {
-		qry.Add("tables", strings.Join(flTables, ","))
+	params := reaper.AddRepairRunParams{
+		Cluster:           *flCluster,
+		Keyspace:          *flKeyspace,
+		Tables:            flTables,
+		Owner:             *flOwner,
+		Cause:             *flCause,
+		Segments:          *flSegments,
+		Parallelism:       flPar,
+		Intensity:         *flIntensity,
+		Incremental:       *flIncremental,
+		Nodes:             flNodes,
+		Datacenters:       flDatacenters,
+		BlacklistedTables: flBlacklistedTables,
 	}
-	qry.Add("owner", *flOwner)
-	qry.Add("cause", *flCause)
-	qry.Add("segmentCount", strconv.Itoa(*flSegments))
-	qry.Add("repairParallelism", flPar.String())
-	qry.Add("intensity", fmt.Sprintf("%0.3f", *flIntensity))
-	qry.Add("incrementalRepair", fmt.Sprintf("%v", *flIncremental))
-	qry.Add("nodes", strings.Join(flNodes, ","))
-	qry.Add("datacenters", strings.Join(flDatacenters, ","))
-	qry.Add("blacklistedTables", strings.Join(flBlacklistedTables, ","))
-
-	ur := makeURL("/repair_run?") + qry.Encode()
 
-	resp, err := http.Post(ur, "application/json", nil)
+	res, err := client.AddRepairRun(ctx, params)
 	if err != nil {
-		return errors.E(errors.IO, op, err)
-	}
-	defer resp.Body.Close()
-
-	var buf bytes.Buffer
-	rd := io.TeeReader(resp.Body, &buf)
-
-	if resp.StatusCode != http.StatusCreated {
-		io.Copy(&buf, rd)
-		return errors.Str(buf.String())
-	}
-
-	var res RepairRun
-	dec := json.NewDecoder(rd)
-
-	if err := dec.Decode(&res); err != nil {
-		return errors.E(errors.IO, op, err)
+		return err
 	}
 
 	color.Yellow("Repair #%v correctly added", res.ID)
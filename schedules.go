@@ -1,117 +1,27 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
+	"os"
 	"sort"
-	"strconv"
-	"strings"
-	"time"
 
 	"github.com/fatih/color"
 	"github.com/vrischmann/flagutil"
 	"github.com/vrischmann/happyreaper/errors"
+	"github.com/vrischmann/happyreaper/reaper"
 )
 
-type ScheduleState string
-
-const (
-	SActive  ScheduleState = "ACTIVE"
-	SPaused  ScheduleState = "PAUSED"
-	SDeleted ScheduleState = "DELETED"
-)
-
-func (s ScheduleState) String() string { return string(s) }
-
-func (s *ScheduleState) Set(str string) error {
-	switch {
-	case strings.EqualFold(str, "active"):
-		*s = SActive
-	case strings.EqualFold(str, "paused"):
-		*s = SPaused
-	case strings.EqualFold(str, "deleted"):
-		*s = SDeleted
-	default:
-		return errors.Errorf("invalid state %q", str)
-	}
-	return nil
-}
-
-type RepairSchedule struct {
-	ID    string `json:"id"`
-	Owner string `json:"owner"`
-
-	ClusterName  string `json:"cluster_name"`
-	KeyspaceName string `json:"keyspace_name"`
-
-	State ScheduleState `json:"state"`
-
-	ColumnFamilies       []string    `json:"column_families"`
-	Intensity            float64     `json:"intensity"`
-	IncrementalRepair    bool        `json:"incremental_repair"`
-	RepairParallelism    Parallelism `json:"repair_parallelism"`
-	ScheduledDaysBetween int         `json:"scheduled_days_between"`
-	SegmentCount         int         `json:"segment_count"`
-
-	CreationTime   *time.Time `json:"creation_time"`
-	PauseTime      *time.Time `json:"pause_time"`
-	NextActivation *time.Time `json:"next_activation"`
-}
-
-func (r RepairSchedule) String() string {
-	s := fmt.Sprintf("{id:%d owner:%q cluster:%q keyspace:%q state:%s cf:%v intensity:%0.3f par:%s daysBetween:%d segments:%d creation:%s pause:%s next:%s}",
-		r.ID, r.Owner,
-		r.ClusterName, r.KeyspaceName,
-		r.State, r.ColumnFamilies,
-		r.Intensity, r.RepairParallelism,
-		r.ScheduledDaysBetween, r.SegmentCount,
-		r.CreationTime, r.PauseTime, r.NextActivation,
-	)
-	return s
-}
-
-func (r RepairSchedule) Format(s fmt.State, verb rune) {
-	switch verb {
-	case 'v':
-		if s.Flag('+') {
-			fmt.Fprintf(s, "%-20s %s\n", "id:", r.ID)
-			fmt.Fprintf(s, "%-20s %s\n", "owner:", r.Owner)
-			fmt.Fprintf(s, "%-20s %s\n", "cluster name:", r.ClusterName)
-			fmt.Fprintf(s, "%-20s %s\n", "keyspace name:", r.KeyspaceName)
-			fmt.Fprintf(s, "%-20s %s\n", "state:", r.State)
-			fmt.Fprintf(s, "%-20s %v\n", "column families:", r.ColumnFamilies)
-			fmt.Fprintf(s, "%-20s %0.3f\n", "intensity:", r.Intensity)
-			fmt.Fprintf(s, "%-20s %s\n", "par:", r.RepairParallelism)
-			fmt.Fprintf(s, "%-20s %d\n", "days between:", r.ScheduledDaysBetween)
-			fmt.Fprintf(s, "%-20s %d\n", "segments:", r.SegmentCount)
-			fmt.Fprintf(s, "%-20s %s\n", "creation time:", r.CreationTime)
-			fmt.Fprintf(s, "%-20s %s\n", "pause time:", r.PauseTime)
-			fmt.Fprintf(s, "%-20s %s\n", "next activation:", r.NextActivation)
-			return
-		}
-		fallthrough
-	case 's':
-		io.WriteString(s, r.String())
-	}
-}
-
 func addSchedule(args []string) error {
-	const op = "addSchedule"
-
 	var (
 		fs                    = flag.NewFlagSet("add-schedule", flag.ContinueOnError)
 		flCluster             = fs.String("cluster", "", "The cluster name")
 		flKeyspace            = fs.String("keyspace", "", "The keyspace name")
 		flTables              flagutil.Strings
-		flOwner               = fs.String("owner", "", "The owner")
-		flSegments            = fs.Int("segments", 200, "The number of segments")
-		flPar                 Parallelism
-		flIntensity           = fs.Float64("intensity", 0.5, "The intensity")
+		flOwner               = fs.String("owner", defaults.Owner, "The owner")
+		flSegments            = fs.Int("segments", defaults.Segments, "The number of segments")
+		flPar                 reaper.Parallelism
+		flIntensity           = fs.Float64("intensity", defaults.Intensity, "The intensity")
 		flIncrementalRepair   = fs.Bool("incremental", false, "Use incremental repairs")
 		flScheduleDaysBetween = fs.Int("schedule-days-between", 14, "Number of days between repairs")
 		flScheduleTriggerTime = fs.String("schedule-trigger-time", "", "Time at which to start the scheduling")
@@ -128,10 +38,6 @@ func addSchedule(args []string) error {
 		return err
 	}
 
-	if *flIncrementalRepair {
-		fmt.Println("NOTE: incremental repairs are not supported yet")
-	}
-
 	switch {
 	case *flCluster == "":
 		return errors.Str("please provide a cluster")
@@ -142,45 +48,25 @@ func addSchedule(args []string) error {
 	}
 
 	if flPar == "" {
-		flPar = Sequential
+		flPar = reaper.Sequential
 	}
 
-	qry := make(url.Values)
-	qry.Add("clusterName", *flCluster)
-	qry.Add("keyspace", *flKeyspace)
-	if len(flTables) > 0 {
-		qry.Add("tables", strings.Join(flTables, ","))
-	}
-	qry.Add("owner", *flOwner)
-	qry.Add("segmentCount", strconv.Itoa(*flSegments))
-	qry.Add("repairParallelism", flPar.String())
-	qry.Add("intensity", fmt.Sprintf("%0.3f", *flIntensity))
-	qry.Add("scheduleDaysBetween", strconv.Itoa(*flScheduleDaysBetween))
-	if *flScheduleTriggerTime != "" {
-		qry.Add("scheduleTriggerTime", *flScheduleTriggerTime)
+	params := reaper.AddScheduleParams{
+		Cluster:             *flCluster,
+		Keyspace:            *flKeyspace,
+		Tables:              flTables,
+		Owner:               *flOwner,
+		Segments:            *flSegments,
+		Parallelism:         flPar,
+		Intensity:           *flIntensity,
+		Incremental:         *flIncrementalRepair,
+		ScheduleDaysBetween: *flScheduleDaysBetween,
+		ScheduleTriggerTime: *flScheduleTriggerTime,
 	}
 
-	ur := makeURL("/repair_schedule?") + qry.Encode()
-
-	resp, err := http.Post(ur, "application/json", nil)
+	res, err := client.AddSchedule(ctx, params)
 	if err != nil {
-		return errors.E(errors.IO, op, err)
-	}
-	defer resp.Body.Close()
-
-	var buf bytes.Buffer
-	rd := io.TeeReader(resp.Body, &buf)
-
-	if resp.StatusCode != http.StatusCreated {
-		io.Copy(&buf, rd)
-		return errors.Str(buf.String())
-	}
-
-	var res RepairSchedule
-	dec := json.NewDecoder(rd)
-
-	if err := dec.Decode(&res); err != nil {
-		return errors.E(errors.IO, op, err)
+		return err
 	}
 
 	color.Yellow("Schedule #%s correctly added", res.ID)
@@ -191,8 +77,6 @@ func addSchedule(args []string) error {
 }
 
 func viewSchedule(args []string) error {
-	const op = "viewSchedule"
-
 	var (
 		fs   = flag.NewFlagSet("view-schedule", flag.ContinueOnError)
 		flID = fs.String("id", "", "The repair ID")
@@ -210,25 +94,13 @@ func viewSchedule(args []string) error {
 		return errors.Str("please provide a valid ID")
 	}
 
-	resp, err := http.Get(makeURL("/repair_schedule/" + *flID))
+	res, err := client.GetSchedule(ctx, *flID)
 	if err != nil {
-		return errors.E(errors.IO, op, err)
-	}
-	defer resp.Body.Close()
-
-	var buf bytes.Buffer
-	rd := io.TeeReader(resp.Body, &buf)
-
-	if resp.StatusCode != http.StatusOK {
-		io.Copy(&buf, rd)
-		return errors.Str(buf.String())
+		return err
 	}
 
-	var res RepairSchedule
-	dec := json.NewDecoder(rd)
-
-	if err := dec.Decode(&res); err != nil {
-		return errors.E(errors.IO, op, err)
+	if flOutput != OutputText {
+		return writeOutput(os.Stdout, flOutput, res)
 	}
 
 	fmt.Printf("%+v\n", res)
@@ -248,48 +120,36 @@ func (s *ScheduleSortBy) Set(str string) error {
 	return nil
 }
 
-func callListSchedules(qry url.Values) ([]RepairSchedule, error) {
-	const op = "callListSchedules"
-
-	resp, err := http.Get(makeURL("/repair_schedule") + "?" + qry.Encode())
-	if err != nil {
-		return nil, errors.E(errors.IO, op, err)
-	}
-	defer resp.Body.Close()
-
-	var buf bytes.Buffer
-	rd := io.TeeReader(resp.Body, &buf)
-
-	if resp.StatusCode != http.StatusOK {
-		io.Copy(&buf, rd)
-		return nil, errors.Str(buf.String())
-	}
-
-	var res []RepairSchedule
-	dec := json.NewDecoder(rd)
+func callListSchedules(cluster, keyspace string) ([]reaper.RepairSchedule, error) {
+	return client.ListSchedules(ctx, cluster, keyspace)
+}
 
-	if err := dec.Decode(&res); err != nil {
-		return nil, errors.E(errors.IO, op, err)
+// activatesBefore orders the schedules by next activation, the ones without a next activation last.
+func activatesBefore(a, b reaper.RepairSchedule) bool {
+	switch {
+	case a.NextActivation == nil:
+		return false
+	case b.NextActivation == nil:
+		return true
+	default:
+		return a.NextActivation.Before(*b.NextActivation)
 	}
-	return res, nil
 }
 
-func sortSchedules(res []RepairSchedule, sortBy ScheduleSortBy, reverse bool) {
+func sortSchedules(res []reaper.RepairSchedule, sortBy ScheduleSortBy, reverse bool) {
 	switch {
 	case sortBy == ScheduleSortByNextActivation && !reverse:
 		sort.Slice(res, func(i, j int) bool {
-			return res[i].NextActivation.Before(*res[j].NextActivation)
+			return activatesBefore(res[i], res[j])
 		})
 	case sortBy == ScheduleSortByNextActivation:
 		sort.Slice(res, func(i, j int) bool {
-			return res[i].NextActivation.After(*res[j].NextActivation)
+			return activatesBefore(res[j], res[i])
 		})
 	}
 }
 
 func nextSchedule(args []string) error {
-	const op = "nextSchedule"
-
 	var fs = flag.NewFlagSet("next-schedule", flag.ContinueOnError)
 
 	err := fs.Parse(args)
@@ -300,7 +160,7 @@ func nextSchedule(args []string) error {
 		return err
 	}
 
-	res, err := callListSchedules(make(url.Values))
+	res, err := callListSchedules("", "")
 	if err != nil {
 		return err
 	}
@@ -312,25 +172,29 @@ func nextSchedule(args []string) error {
 	sortSchedules(res, ScheduleSortByNextActivation, false)
 
 	schedule := res[0]
+
+	if flOutput != OutputText {
+		return writeOutput(os.Stdout, flOutput, schedule)
+	}
+
 	fmt.Printf("%+v\n\n", schedule)
 
 	return nil
 }
 
 func listSchedules(args []string) error {
-	const op = "listSchedules"
-
 	var (
 		fs            = flag.NewFlagSet("list-schedules", flag.ContinueOnError)
-		flCluster     = fs.String("cluster", "", "The cluster name")
-		flKeyspace    = fs.String("keyspace", "", "The keyspace name")
-		flState       ScheduleState
+		filter        = newScheduleFilter(fs)
 		flSortBy      ScheduleSortBy
 		flReverseSort = fs.Bool("reverse-sort", false, "Revert the sorting")
+		flTable       = fs.Bool("table", false, "Print a table with one schedule per row")
+		flColumns     flagutil.Strings
+		flColor       = fs.Bool("color", true, "Color the table rows by state")
 	)
 
-	fs.Var(&flState, "state", "Filter by state")
 	fs.Var(&flSortBy, "sort-by", "Sort by next-activation")
+	fs.Var(&flColumns, "columns", "The columns to show in the table (comma separated list of fields)")
 
 	err := fs.Parse(args)
 	switch {
@@ -340,44 +204,40 @@ func listSchedules(args []string) error {
 		return err
 	}
 
-	qry := make(url.Values)
-	if *flCluster != "" {
-		qry.Add("clusterName", *flCluster)
-	}
-	if *flKeyspace != "" {
-		qry.Add("keyspaceName", *flKeyspace)
-	}
-
-	res, err := callListSchedules(qry)
+	schedules, err := filter.list()
 	if err != nil {
 		return err
 	}
 
-	sortSchedules(res, flSortBy, *flReverseSort)
+	sortSchedules(schedules, flSortBy, *flReverseSort)
 
-	for _, sched := range res {
-		keyspaceOK := *flKeyspace == "" || sched.KeyspaceName == *flKeyspace
-		stateOK := flState == "" || sched.State == flState
+	if flOutput != OutputText {
+		return writeOutput(os.Stdout, flOutput, schedules)
+	}
 
-		if !keyspaceOK || !stateOK {
-			continue
-		}
+	if *flTable {
+		return printSchedulesTable(os.Stdout, schedules, flColumns, *flColor)
+	}
 
+	for _, sched := range schedules {
 		fmt.Printf("%+v\n\n", sched)
 	}
 
 	return nil
 }
 
-func deleteSchedule(args []string) error {
-	const op = "deleteSchedule"
-
+func updateSchedule(args []string) error {
 	var (
-		fs      = flag.NewFlagSet("delete-schedule", flag.ContinueOnError)
-		flID    = fs.String("id", "", "The schedule ID")
-		flOwner = fs.String("owner", "", "The owner")
+		fs                    = flag.NewFlagSet("update-schedule", flag.ContinueOnError)
+		flID                  = fs.String("id", "", "The schedule ID")
+		flOwner               = fs.String("owner", "", "The new owner")
+		flPar                 reaper.Parallelism
+		flIntensity           = fs.Float64("intensity", 0, "The new intensity")
+		flScheduleDaysBetween = fs.Int("schedule-days-between", 0, "The new number of days between repairs")
 	)
 
+	fs.Var(&flPar, "par", "The new parallelism")
+
 	err := fs.Parse(args)
 	switch {
 	case err == flag.ErrHelp:
@@ -386,82 +246,107 @@ func deleteSchedule(args []string) error {
 		return err
 	}
 
-	if *flID == "" {
-		return errors.Str("please provide a valid ID")
-	}
-	if *flOwner == "" {
-		return errors.Str("please provide a valid owner")
+	params := reaper.UpdateScheduleParams{
+		Owner:               *flOwner,
+		Parallelism:         flPar,
+		Intensity:           *flIntensity,
+		ScheduleDaysBetween: *flScheduleDaysBetween,
 	}
 
-	qry := make(url.Values)
-	qry.Add("owner", *flOwner)
-
-	ur := makeURL("/repair_schedule/"+*flID) + "?" + qry.Encode()
-
-	req, err := http.NewRequest("DELETE", ur, nil)
-	if err != nil {
-		return errors.E(errors.Invalid, op, err)
+	switch {
+	case *flID == "":
+		return errors.Str("please provide a valid ID")
+	case params == reaper.UpdateScheduleParams{}:
+		return errors.Str("please provide at least one parameter to change")
+	case *flIntensity < 0 || *flIntensity > 1:
+		return errors.Str("the intensity must be between 0 and 1")
+	case *flScheduleDaysBetween < 0:
+		return errors.Str("the number of days between repairs must be positive")
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	before, err := client.GetSchedule(ctx, *flID)
 	if err != nil {
-		return errors.E(errors.IO, op, err)
+		return err
 	}
-	defer resp.Body.Close()
-
-	var buf bytes.Buffer
-	rd := io.TeeReader(resp.Body, &buf)
 
-	if resp.StatusCode != http.StatusOK {
-		io.Copy(&buf, rd)
-		return errors.Str(buf.String())
+	if before.State != reaper.SActive && before.State != reaper.SPaused {
+		return errors.E(errors.Conflict, "updateSchedule", errors.Errorf("schedule %s is %s, only an ACTIVE or PAUSED schedule can be changed", before.ID, before.State))
 	}
 
-	var res RepairSchedule
-	dec := json.NewDecoder(rd)
+	if _, err := client.UpdateSchedule(ctx, before.ID, params); err != nil {
+		return err
+	}
 
-	if err := dec.Decode(&res); err != nil {
-		return errors.E(errors.IO, op, err)
+	after, err := client.GetSchedule(ctx, before.ID)
+	if err != nil {
+		return err
 	}
 
-	color.Yellow("Schedule %s correctly deleted", *flID)
+	color.Yellow("Schedule %s correctly updated", after.ID)
 
-	fmt.Printf("%+v\n", res)
+	printChanges(os.Stdout, scheduleFieldNames(), scheduleRecord(before), scheduleRecord(after))
 
 	return nil
 }
 
-func changeScheduleState(repairID string, state ScheduleState) error {
-	const op = "changeScheduleState"
+func deleteSchedule(args []string) error {
+	var (
+		fs     = flag.NewFlagSet("delete-schedule", flag.ContinueOnError)
+		flID   = fs.String("id", "", "The schedule ID, otherwise all the schedules matching the filters are deleted")
+		filter = newScheduleFilter(fs)
+		opts   = newBulkOptions(fs)
+	)
 
-	qry := make(url.Values)
-	qry.Add("state", state.String())
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
 
-	ur := makeURL("/repair_schedule/"+repairID) + "?" + qry.Encode()
+	if *flID == "" {
+		if filter.isZero() {
+			return errors.Str("please provide a valid ID or at least one filter")
+		}
 
-	req, err := http.NewRequest("PUT", ur, nil)
-	if err != nil {
-		return errors.E(errors.Invalid, op, err)
+		return bulkSchedules("Delete", filter, opts, func(sched reaper.RepairSchedule) error {
+			_, err := client.DeleteSchedule(ctx, sched.ID, sched.Owner)
+			return err
+		})
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	// With an ID the owner is required, Reaper checks it matches the owner of the schedule.
+	owner := *filter.owner
+	if owner == "" {
+		owner = defaults.Owner
+	}
+	if owner == "" {
+		return errors.Str("please provide a valid owner")
+	}
+
+	res, err := client.DeleteSchedule(ctx, *flID, owner)
 	if err != nil {
-		return errors.E(errors.IO, op, err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	var buf bytes.Buffer
-	rd := io.TeeReader(resp.Body, &buf)
+	color.Yellow("Schedule %s correctly deleted", *flID)
+
+	fmt.Printf("%+v\n", res)
+
+	return nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		io.Copy(&buf, rd)
-		return errors.Str(buf.String())
+func changeScheduleState(scheduleID string, state reaper.ScheduleState) error {
+	res, err := client.ChangeScheduleState(ctx, scheduleID, state)
+	if err != nil {
+		return err
 	}
 
 	color.Yellow("State changed to %s", state)
 
-	if buf.Len() > 0 {
-		fmt.Println(buf.String())
+	if res.ID != "" {
+		fmt.Printf("%+v\n", res)
 	}
 
 	return nil
@@ -469,8 +354,10 @@ func changeScheduleState(repairID string, state ScheduleState) error {
 
 func pauseSchedule(args []string) error {
 	var (
-		fs   = flag.NewFlagSet("pause-schedule", flag.ContinueOnError)
-		flID = fs.String("id", "", "The schedule ID")
+		fs     = flag.NewFlagSet("pause-schedule", flag.ContinueOnError)
+		flID   = fs.String("id", "", "The schedule ID, otherwise all the schedules matching the filters are paused")
+		filter = newScheduleFilter(fs)
+		opts   = newBulkOptions(fs)
 	)
 
 	err := fs.Parse(args)
@@ -481,17 +368,29 @@ func pauseSchedule(args []string) error {
 		return err
 	}
 
-	if *flID == "" {
-		return errors.Str("please provide a valid ID")
+	switch {
+	case *flID != "":
+		return changeScheduleState(*flID, reaper.SPaused)
+	case filter.isZero():
+		return errors.Str("please provide a valid ID or at least one filter")
+	}
+
+	if filter.state == "" {
+		filter.state = reaper.SActive
 	}
 
-	return changeScheduleState(*flID, SPaused)
+	return bulkSchedules("Pause", filter, opts, func(sched reaper.RepairSchedule) error {
+		_, err := client.ChangeScheduleState(ctx, sched.ID, reaper.SPaused)
+		return err
+	})
 }
 
 func resumeSchedule(args []string) error {
 	var (
-		fs   = flag.NewFlagSet("resume-schedule", flag.ContinueOnError)
-		flID = fs.String("id", "", "The schedule ID")
+		fs     = flag.NewFlagSet("resume-schedule", flag.ContinueOnError)
+		flID   = fs.String("id", "", "The schedule ID, otherwise all the schedules matching the filters are resumed")
+		filter = newScheduleFilter(fs)
+		opts   = newBulkOptions(fs)
 	)
 
 	err := fs.Parse(args)
@@ -502,9 +401,19 @@ func resumeSchedule(args []string) error {
 		return err
 	}
 
-	if *flID == "" {
-		return errors.Str("please provide a valid ID")
+	switch {
+	case *flID != "":
+		return changeScheduleState(*flID, reaper.SActive)
+	case filter.isZero():
+		return errors.Str("please provide a valid ID or at least one filter")
 	}
 
-	return changeScheduleState(*flID, SActive)
+	if filter.state == "" {
+		filter.state = reaper.SPaused
+	}
+
+	return bulkSchedules("Resume", filter, opts, func(sched reaper.RepairSchedule) error {
+		_, err := client.ChangeScheduleState(ctx, sched.ID, reaper.SActive)
+		return err
+	})
 }
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/vrischmann/flagutil"
+	"github.com/vrischmann/happyreaper/errors"
+	"github.com/vrischmann/happyreaper/reaper"
+)
+
+// hostFailures summarizes the segments coordinated by a host.
+type hostFailures struct {
+	Host           string `json:"host" yaml:"host"`
+	Segments       int    `json:"segments" yaml:"segments"`
+	FailedSegments int    `json:"failed_segments" yaml:"failed_segments"`
+	Failures       int    `json:"failures" yaml:"failures"`
+}
+
+// summarizeFailures groups the segments by coordinator host, the hosts with the most failures first.
+func summarizeFailures(segments []reaper.Segment) []hostFailures {
+	m := make(map[string]*hostFailures)
+	for _, seg := range segments {
+		hf, ok := m[seg.CoordinatorHost]
+		if !ok {
+			hf = &hostFailures{Host: seg.CoordinatorHost}
+			m[seg.CoordinatorHost] = hf
+		}
+
+		hf.Segments++
+		hf.Failures += seg.FailCount
+		if seg.FailCount > 0 {
+			hf.FailedSegments++
+		}
+	}
+
+	res := make([]hostFailures, 0, len(m))
+	for _, hf := range m {
+		res = append(res, *hf)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Failures != res[j].Failures {
+			return res[i].Failures > res[j].Failures
+		}
+		return res[i].Host < res[j].Host
+	})
+
+	return res
+}
+
+func printFailuresSummary(w io.Writer, summary []hostFailures) {
+	t := table{
+		header: []string{"COORDINATOR HOST", "SEGMENTS", "FAILED SEGMENTS", "FAILURES"},
+	}
+	for _, hf := range summary {
+		host := hf.Host
+		if host == "" {
+			host = "-"
+		}
+		t.addRow([]string{host, strconv.Itoa(hf.Segments), strconv.Itoa(hf.FailedSegments), strconv.Itoa(hf.Failures)}, nil)
+	}
+	t.print(w)
+}
+
+func listSegments(args []string) error {
+	var (
+		fs        = flag.NewFlagSet("list-segments", flag.ContinueOnError)
+		flID      = fs.String("id", "", "The repair ID")
+		flState   reaper.SegmentState
+		flFailed  = fs.Bool("failed", false, "Only show the segments which failed at least once")
+		flSummary = fs.Bool("summary", false, "Print a summary of the failures per coordinator host instead of the segments")
+		flColumns flagutil.Strings
+		flColor   = fs.Bool("color", true, "Color the table rows by state")
+	)
+
+	fs.Var(&flState, "state", "Filter by segment state")
+	fs.Var(&flColumns, "columns", "The columns to show in the table (comma separated list of fields)")
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if *flID == "" {
+		return errors.Str("please provide a valid ID")
+	}
+
+	res, err := client.ListSegments(ctx, *flID)
+	if err != nil {
+		return err
+	}
+
+	segments := make([]reaper.Segment, 0, len(res))
+	for _, seg := range res {
+		switch {
+		case flState != "" && seg.State != flState:
+			continue
+		case *flFailed && seg.FailCount <= 0:
+			continue
+		}
+
+		segments = append(segments, seg)
+	}
+
+	if *flSummary {
+		summary := summarizeFailures(segments)
+
+		if flOutput != OutputText {
+			return writeOutput(os.Stdout, flOutput, summary)
+		}
+
+		printFailuresSummary(os.Stdout, summary)
+
+		return nil
+	}
+
+	if flOutput != OutputText {
+		return writeOutput(os.Stdout, flOutput, segments)
+	}
+
+	if err := printSegmentsTable(os.Stdout, segments, flColumns, *flColor); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d segments\n", len(segments))
+
+	return nil
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/vrischmann/happyreaper/errors"
+	"github.com/vrischmann/happyreaper/reaper"
+)
+
+// maxStaggerHorizon limits the number of days over which the load of the schedules is computed when their cycles have no small common multiple.
+const maxStaggerHorizon = 366
+
+// staggerOffsets spreads the schedules over their cycle of ScheduledDaysBetween days and returns the day of their first activation, by ID,
+// counted from the day of from.
+// Each schedule is followed by a gap proportional to its weight, which is its number of segments if weighted is true.
+// The schedules whose number of segments is unknown get the average weight of the others of their cycle.
+//
+// The schedules of the shortest cycle start on from. The other cycles, from the shortest to the longest, are then shifted to the days
+// least used by the schedules already placed and by the fixed schedules, which don't move.
+func staggerOffsets(schedules, fixed []reaper.RepairSchedule, from time.Time, weighted bool) map[string]int {
+	cycles := make(map[int][]reaper.RepairSchedule)
+	for _, sched := range schedules {
+		if sched.ScheduledDaysBetween > 0 {
+			cycles[sched.ScheduledDaysBetween] = append(cycles[sched.ScheduledDaysBetween], sched)
+		}
+	}
+
+	lengths := make([]int, 0, len(cycles))
+	for days := range cycles {
+		lengths = append(lengths, days)
+	}
+	sort.Ints(lengths)
+
+	base := make(map[string]int, len(schedules))
+	for _, days := range lengths {
+		scheds := cycles[days]
+
+		// Keep the current order of the activations to move the schedules as little as possible.
+		sort.SliceStable(scheds, func(i, j int) bool {
+			a, b := scheds[i].NextActivation, scheds[j].NextActivation
+			switch {
+			case a == nil || b == nil:
+				return b == nil && a != nil
+			case !a.Equal(*b):
+				return a.Before(*b)
+			default:
+				return scheduleKey(scheds[i].ClusterName, scheds[i].KeyspaceName, scheds[i].ColumnFamilies) <
+					scheduleKey(scheds[j].ClusterName, scheds[j].KeyspaceName, scheds[j].ColumnFamilies)
+			}
+		})
+
+		weights := make([]float64, len(scheds))
+		for i := range weights {
+			weights[i] = 1
+		}
+		if weighted {
+			var (
+				known float64
+				n     int
+			)
+			for _, sched := range scheds {
+				if count := segmentCount(sched); count > 0 {
+					known += float64(count)
+					n++
+				}
+			}
+			for i, sched := range scheds {
+				switch count := segmentCount(sched); {
+				case count > 0:
+					weights[i] = float64(count)
+				case n > 0:
+					weights[i] = known / float64(n)
+				}
+			}
+		}
+
+		var total float64
+		for _, w := range weights {
+			total += w
+		}
+
+		var cum float64
+		for i, sched := range scheds {
+			base[sched.ID] = int(float64(days) * cum / total)
+			cum += weights[i]
+		}
+	}
+
+	// The load is the number of activations of each day, over a period long enough to repeat itself.
+	horizon := 1
+	for _, sched := range append(append([]reaper.RepairSchedule(nil), schedules...), fixed...) {
+		if days := sched.ScheduledDaysBetween; days > 0 {
+			horizon = lcm(horizon, days)
+		}
+		if horizon > maxStaggerHorizon {
+			horizon = maxStaggerHorizon
+			break
+		}
+	}
+	if len(lengths) > 0 && horizon < lengths[len(lengths)-1] {
+		horizon = lengths[len(lengths)-1]
+	}
+
+	load := make([]int, horizon)
+	occupy := func(day, days int) {
+		for t := day; t < horizon; t += days {
+			load[t]++
+		}
+	}
+
+	for _, sched := range fixed {
+		days := sched.ScheduledDaysBetween
+		if days <= 0 || sched.NextActivation == nil {
+			continue
+		}
+		day := daysBetween(from, *sched.NextActivation) % days
+		if day < 0 {
+			day += days
+		}
+		occupy(day, days)
+	}
+
+	res := make(map[string]int, len(schedules))
+	for i, days := range lengths {
+		scheds := cycles[days]
+
+		// The shift keeps the order of the cycle, its last schedule stays in the cycle.
+		var best int
+		if i > 0 {
+			bestCost := -1
+			for shift := 0; shift < days-base[scheds[len(scheds)-1].ID]; shift++ {
+				var cost int
+				for _, sched := range scheds {
+					for t := base[sched.ID] + shift; t < horizon; t += days {
+						cost += load[t]
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = shift, cost
+				}
+			}
+		}
+
+		for _, sched := range scheds {
+			res[sched.ID] = base[sched.ID] + best
+			occupy(res[sched.ID], days)
+		}
+	}
+
+	return res
+}
+
+// lcm returns the least common multiple of a and b.
+func lcm(a, b int) int {
+	x, y := a, b
+	for y != 0 {
+		x, y = y, x%y
+	}
+	return a / x * b
+}
+
+// daysBetween returns the number of days between the day of a and the day of b, in the local time zone.
+func daysBetween(a, b time.Time) int {
+	return int(math.Round(startOfDay(b.In(time.Local)).Sub(startOfDay(a.In(time.Local))).Hours() / 24))
+}
+
+// sameCycleDay returns true if a and b are apart by whole cycles of days, moving an activation by whole cycles changes nothing.
+func sameCycleDay(a, b time.Time, days int) bool {
+	return daysBetween(a, b)%days == 0
+}
+
+// busiestDay returns the day with the most activations and their number.
+func busiestDay(acts []activation) (string, int) {
+	counts := make(map[string]int)
+	for _, act := range acts {
+		counts[act.Time.In(time.Local).Format(myTimeLayout)]++
+	}
+
+	var (
+		day string
+		max int
+	)
+	for d, n := range counts {
+		if n > max || (n == max && d < day) {
+			day, max = d, n
+		}
+	}
+
+	return day, max
+}
+
+// printStaggerSummary prints the busiest day of the cycle before and after the changes.
+func printStaggerSummary(w io.Writer, before, after []reaper.RepairSchedule, from time.Time) {
+	days := 0
+	for _, sched := range before {
+		if sched.ScheduledDaysBetween > days {
+			days = sched.ScheduledDaysBetween
+		}
+	}
+	until := from.AddDate(0, 0, days)
+
+	dayBefore, maxBefore := busiestDay(projectActivations(before, from, until))
+	dayAfter, maxAfter := busiestDay(projectActivations(after, from, until))
+
+	fmt.Fprintf(w, "Busiest day over the next %d days: %d repairs on %s before, %d repairs on %s after.\n",
+		days, maxBefore, dayBefore, maxAfter, dayAfter)
+}
+
+func staggerSchedules(args []string) error {
+	var (
+		fs         = flag.NewFlagSet("stagger-schedules", flag.ContinueOnError)
+		flCluster  = fs.String("cluster", "", "The cluster of the schedules")
+		flFrom     = fs.String("from", "", "The first day of the new cycle, as YYYY-MM-DD (default the day of the first activation, or tomorrow)")
+		flWeighted = fs.Bool("weight-segments", false, "Leave more time after the schedules with more segments")
+		flDryRun   = fs.Bool("dry-run", false, "Only print the changes which would be made")
+		flYes      = fs.Bool("yes", false, "Don't ask for confirmation")
+	)
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if *flCluster == "" {
+		return errors.Str("please provide a cluster")
+	}
+
+	var (
+		now  = time.Now()
+		from time.Time
+	)
+	if *flFrom != "" {
+		from, err = time.ParseInLocation(myTimeLayout, *flFrom, time.Local)
+		if err != nil {
+			return errors.E(errors.Invalid, "staggerSchedules", err)
+		}
+		if !from.After(now) {
+			return errors.Str("please provide a day in the future")
+		}
+	}
+
+	res, err := callListSchedules(*flCluster, "")
+	if err != nil {
+		return err
+	}
+
+	var schedules []reaper.RepairSchedule
+	for _, sched := range res {
+		if sched.State == reaper.SActive {
+			schedules = append(schedules, sched)
+		}
+	}
+
+	if from.IsZero() {
+		// Start the cycle on the day of the first activation, so that running the command again changes nothing.
+		for _, sched := range schedules {
+			if sched.NextActivation == nil {
+				continue
+			}
+			if day := startOfDay(sched.NextActivation.In(time.Local)); day.After(now) && (from.IsZero() || day.Before(from)) {
+				from = day
+			}
+		}
+		if from.IsZero() {
+			from = startOfDay(now).AddDate(0, 0, 1)
+		}
+	}
+
+	// The schedules whose number of segments is unknown can't be recreated identically, they stay where they are.
+	var movable, fixed []reaper.RepairSchedule
+	for _, sched := range schedules {
+		if segmentCount(sched) == 0 {
+			fmt.Fprintf(os.Stderr, "schedule %s of %s left as is, Reaper doesn't report its number of segments\n",
+				sched.ID, scheduleKey(sched.ClusterName, sched.KeyspaceName, sched.ColumnFamilies))
+			fixed = append(fixed, sched)
+			continue
+		}
+		movable = append(movable, sched)
+	}
+
+	offsets := staggerOffsets(movable, fixed, from, *flWeighted)
+
+	var (
+		changes []scheduleChange
+		after   = make([]reaper.RepairSchedule, 0, len(schedules))
+	)
+	for _, sched := range schedules {
+		offset, ok := offsets[sched.ID]
+		if !ok {
+			after = append(after, sched)
+			continue
+		}
+
+		// Keep the time of day of the current activations.
+		next := from.AddDate(0, 0, offset)
+		if sched.NextActivation != nil {
+			t := sched.NextActivation.In(time.Local)
+			next = next.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute)
+		}
+
+		moved := sched
+		moved.NextActivation = &next
+		after = append(after, moved)
+
+		if sched.NextActivation != nil && sameCycleDay(*sched.NextActivation, next, sched.ScheduledDaysBetween) {
+			continue
+		}
+
+		// Every parameter is copied, the schedule must not change once recreated.
+		spec, err := importSpec(sched, sched.ClusterName, now)
+		if err != nil {
+			return errors.E(errors.Invalid, "staggerSchedules", errors.Errorf("schedule %s: %s", sched.ID, err))
+		}
+		spec.TriggerTime = next.Format(time.RFC3339)
+
+		changes = append(changes, scheduleChange{
+			Action: actionReplace,
+			Key:    spec.key(),
+			ID:     sched.ID,
+			Changes: []fieldChange{
+				{Field: "next_activation", Before: formatTime(sched.NextActivation), After: spec.TriggerTime},
+			},
+			spec:    spec,
+			current: sched,
+		})
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].spec.TriggerTime < changes[j].spec.TriggerTime })
+
+	if flOutput != OutputText {
+		var w io.Writer = os.Stdout
+		if !*flDryRun {
+			w = previewWriter()
+		}
+		if err := writeOutput(w, flOutput, changes); err != nil {
+			return err
+		}
+	} else {
+		if len(changes) == 0 {
+			fmt.Println("No changes, the schedules are already staggered.")
+			return nil
+		}
+		printPlan(os.Stdout, changes)
+		fmt.Println()
+		printStaggerSummary(os.Stdout, schedules, after, from)
+	}
+
+	if *flDryRun || len(changes) == 0 {
+		return nil
+	}
+
+	if !*flYes {
+		ok, err := confirm("Recreate these %d schedules?", len(changes))
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	// Stop at the first failure, a schedule might have been deleted without being recreated.
+	results := make([]bulkResult, 0, len(changes))
+	for _, c := range changes {
+		if err := c.apply(); err != nil {
+			reportBulk(results)
+			return err
+		}
+		results = append(results, bulkResult{ID: c.Key})
+	}
+
+	return reportBulk(results)
+}
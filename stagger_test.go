@@ -0,0 +1,154 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vrischmann/happyreaper/reaper"
+)
+
+func TestStaggerOffsets(t *testing.T) {
+	at := func(days int) *time.Time {
+		t := testNow.AddDate(0, 0, days)
+		return &t
+	}
+	sched := func(id string, next *time.Time, segments int) reaper.RepairSchedule {
+		s := testSchedule(id, "ks"+id)
+		s.NextActivation, s.SegmentCount = next, segments
+		return s
+	}
+
+	perNode := sched("b", at(1), 0)
+	perNode.SegmentCountPerNode = 300
+
+	weekly := sched("w", at(2), 200)
+	weekly.ScheduledDaysBetween = 7
+	weekly2 := sched("x", at(3), 200)
+	weekly2.ScheduledDaysBetween = 7
+
+	noCycle := sched("z", at(0), 200)
+	noCycle.ScheduledDaysBetween = 0
+
+	fixedOnDay1 := sched("f", at(1), 0)
+	fixedBefore := sched("g", at(-13), 0)
+
+	testCases := []struct {
+		name      string
+		schedules []reaper.RepairSchedule
+		fixed     []reaper.RepairSchedule
+		weighted  bool
+		exp       map[string]int
+	}{
+		{
+			"even",
+			[]reaper.RepairSchedule{sched("a", at(0), 100), sched("b", at(0), 100), sched("c", at(0), 100), sched("d", at(0), 100)},
+			nil,
+			false,
+			map[string]int{"a": 0, "b": 3, "c": 7, "d": 10},
+		},
+		{
+			"ordered by next activation",
+			[]reaper.RepairSchedule{sched("a", nil, 100), sched("b", at(5), 100), sched("c", at(1), 100)},
+			nil,
+			false,
+			map[string]int{"c": 0, "b": 4, "a": 9},
+		},
+		{
+			"weighted",
+			[]reaper.RepairSchedule{sched("a", at(0), 100), sched("b", at(1), 300)},
+			nil,
+			true,
+			map[string]int{"a": 0, "b": 3},
+		},
+		{
+			"weighted by the segments per node",
+			[]reaper.RepairSchedule{sched("a", at(0), 100), perNode},
+			nil,
+			true,
+			map[string]int{"a": 0, "b": 3},
+		},
+		{
+			"unknown segments get the average",
+			[]reaper.RepairSchedule{sched("a", at(0), 100), sched("b", at(1), 0), sched("c", at(2), 300)},
+			nil,
+			true,
+			map[string]int{"a": 0, "b": 2, "c": 7},
+		},
+		{
+			"no known segments",
+			[]reaper.RepairSchedule{sched("a", at(0), 0), sched("b", at(1), 0)},
+			nil,
+			true,
+			map[string]int{"a": 0, "b": 7},
+		},
+		{
+			"cycles are combined",
+			[]reaper.RepairSchedule{sched("a", at(0), 100), sched("b", at(1), 100), weekly, weekly2},
+			nil,
+			false,
+			map[string]int{"a": 1, "b": 8, "w": 0, "x": 3},
+		},
+		{
+			"fixed schedules are avoided",
+			[]reaper.RepairSchedule{sched("a", at(0), 100), weekly, weekly2},
+			[]reaper.RepairSchedule{fixedOnDay1},
+			false,
+			map[string]int{"a": 2, "w": 0, "x": 3},
+		},
+		{
+			"fixed schedules activated before from",
+			[]reaper.RepairSchedule{sched("a", at(0), 100), weekly, weekly2},
+			[]reaper.RepairSchedule{fixedBefore},
+			false,
+			map[string]int{"a": 2, "w": 0, "x": 3},
+		},
+		{
+			"fixed schedules don't move the shortest cycle",
+			[]reaper.RepairSchedule{weekly, weekly2},
+			[]reaper.RepairSchedule{sched("f", at(0), 0)},
+			false,
+			map[string]int{"w": 0, "x": 3},
+		},
+		{
+			"no cycle",
+			[]reaper.RepairSchedule{noCycle},
+			nil,
+			false,
+			map[string]int{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := staggerOffsets(tc.schedules, tc.fixed, testNow, tc.weighted)
+			if !reflect.DeepEqual(res, tc.exp) {
+				t.Errorf("got %v, expected %v", res, tc.exp)
+			}
+		})
+	}
+}
+
+func TestSameCycleDay(t *testing.T) {
+	testCases := []struct {
+		name string
+		a, b time.Time
+		days int
+		exp  bool
+	}{
+		{"same time", testNow, testNow, 14, true},
+		{"same day", testNow, testNow.Add(3 * time.Hour), 14, true},
+		{"one cycle later", testNow, testNow.AddDate(0, 0, 14), 14, true},
+		{"one cycle earlier", testNow, testNow.AddDate(0, 0, -7), 7, true},
+		{"other day", testNow, testNow.AddDate(0, 0, 3), 14, false},
+		{"two cycles and a day", testNow, testNow.AddDate(0, 0, 15), 7, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if res := sameCycleDay(tc.a, tc.b, tc.days); res != tc.exp {
+				t.Errorf("got %v, expected %v", res, tc.exp)
+			}
+		})
+	}
+}
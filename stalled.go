@@ -0,0 +1,379 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/vrischmann/happyreaper/errors"
+	"github.com/vrischmann/happyreaper/reaper"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// progressSample is the number of segments repaired by a run at some point in time.
+type progressSample struct {
+	Time     time.Time `yaml:"time"`
+	Repaired int       `yaml:"repaired"`
+}
+
+// progressHistory records the progress samples of the RUNNING runs between invocations of stalled-repairs.
+type progressHistory struct {
+	Runs map[string][]progressSample `yaml:"runs"`
+}
+
+func readProgressHistory(path string) (progressHistory, error) {
+	const op = "readProgressHistory"
+
+	res := progressHistory{Runs: make(map[string][]progressSample)}
+
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return res, nil
+	case err != nil:
+		return res, errors.E(errors.IO, op, err)
+	}
+
+	if err := yaml.Unmarshal(data, &res); err != nil {
+		return res, errors.E(errors.Invalid, op, err)
+	}
+	if res.Runs == nil {
+		res.Runs = make(map[string][]progressSample)
+	}
+
+	return res, nil
+}
+
+func writeProgressHistory(path string, h progressHistory) error {
+	const op = "writeProgressHistory"
+
+	data, err := yaml.Marshal(h)
+	if err != nil {
+		return errors.E(errors.Invalid, op, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.E(errors.IO, op, err)
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return errors.E(errors.IO, op, err)
+	}
+
+	return nil
+}
+
+// baselineSample returns the most recent sample taken at least window before now.
+func baselineSample(samples []progressSample, now time.Time, window time.Duration) (progressSample, bool) {
+	var (
+		res   progressSample
+		found bool
+	)
+	for _, s := range samples {
+		if now.Sub(s.Time) >= window && (!found || s.Time.After(res.Time)) {
+			res, found = s, true
+		}
+	}
+	return res, found
+}
+
+// pruneSamples drops the samples which are not needed anymore to compute the progress over window.
+func pruneSamples(samples []progressSample, now time.Time, window time.Duration) []progressSample {
+	baseline, found := baselineSample(samples, now, window)
+
+	var res []progressSample
+	for _, s := range samples {
+		if now.Sub(s.Time) < window || (found && s.Time.Equal(baseline.Time)) {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+const (
+	progressOK         = "ok"
+	progressStalled    = "stalled"
+	progressCollecting = "collecting"
+)
+
+// runProgress is the progress of a RUNNING run over the window.
+type runProgress struct {
+	ID       string `json:"id" yaml:"id"`
+	Cluster  string `json:"cluster_name" yaml:"cluster_name"`
+	Keyspace string `json:"keyspace_name" yaml:"keyspace_name"`
+
+	Total  int       `json:"total_segments" yaml:"total_segments"`
+	From   int       `json:"from_segments_repaired" yaml:"from_segments_repaired"`
+	To     int       `json:"to_segments_repaired" yaml:"to_segments_repaired"`
+	Since  time.Time `json:"since" yaml:"since"`
+	Rate   float64   `json:"rate_per_hour" yaml:"rate_per_hour"`
+	Status string    `json:"status" yaml:"status"`
+}
+
+// newRunProgress computes the progress of run since baseline, it is stalled if no segment was repaired
+// or the rate is below minRate segments per hour.
+func newRunProgress(run reaper.RepairRun, baseline progressSample, now time.Time, minRate float64) runProgress {
+	res := runProgress{
+		ID:       run.ID,
+		Cluster:  run.ClusterName,
+		Keyspace: run.KeyspaceName,
+		Total:    run.TotalSegments,
+		From:     baseline.Repaired,
+		To:       run.SegmentsRepaired,
+		Since:    baseline.Time,
+		Status:   progressOK,
+	}
+
+	if hours := now.Sub(baseline.Time).Hours(); hours > 0 {
+		res.Rate = float64(res.To-res.From) / hours
+	}
+	if res.To <= res.From || res.Rate < minRate {
+		res.Status = progressStalled
+	}
+
+	return res
+}
+
+// listRunningRuns returns the RUNNING runs of cluster and keyspace, both optional.
+func listRunningRuns(cluster, keyspace string) ([]reaper.RepairRun, error) {
+	res, err := client.ListRepairRuns(ctx, reaper.Running)
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []reaper.RepairRun
+	for _, run := range res {
+		if run.State == reaper.Running {
+			runs = append(runs, run)
+		}
+	}
+
+	return filterRuns(runs, cluster, keyspace), nil
+}
+
+// filterRuns returns the runs of cluster and keyspace, both optional.
+func filterRuns(runs []reaper.RepairRun, cluster, keyspace string) []reaper.RepairRun {
+	var res []reaper.RepairRun
+	for _, run := range runs {
+		if (cluster == "" || cluster == run.ClusterName) && (keyspace == "" || keyspace == run.KeyspaceName) {
+			res = append(res, run)
+		}
+	}
+	return res
+}
+
+// pruneHistory forgets the runs not running anymore, a run paused for a while is sampled from scratch once resumed.
+// running must contain the RUNNING runs of all the clusters since the history is shared by the invocations with different filters.
+func pruneHistory(h progressHistory, running []reaper.RepairRun) {
+	ids := make(map[string]struct{}, len(running))
+	for _, run := range running {
+		ids[run.ID] = struct{}{}
+	}
+	for id := range h.Runs {
+		if _, ok := ids[id]; !ok {
+			delete(h.Runs, id)
+		}
+	}
+}
+
+// sampleLive samples the runs twice, window apart.
+func sampleLive(cluster, keyspace string, window time.Duration, minRate float64) ([]runProgress, error) {
+	before, err := listRunningRuns(cluster, keyspace)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+
+	fmt.Fprintf(os.Stderr, "sampling %d running repairs for %s\n", len(before), window)
+
+	select {
+	case <-time.After(window):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	after, err := listRunningRuns(cluster, keyspace)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	baselines := make(map[string]progressSample, len(before))
+	for _, run := range before {
+		baselines[run.ID] = progressSample{Time: start, Repaired: run.SegmentsRepaired}
+	}
+
+	var res []runProgress
+	for _, run := range after {
+		if baseline, ok := baselines[run.ID]; ok {
+			res = append(res, newRunProgress(run, baseline, now, minRate))
+		}
+	}
+
+	return res, nil
+}
+
+// samplePersisted adds a sample of the runs to the history in path and compares it to the samples of the previous invocations.
+func samplePersisted(path, cluster, keyspace string, window time.Duration, minRate float64) ([]runProgress, error) {
+	history, err := readProgressHistory(path)
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := listRunningRuns("", "")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	pruneHistory(history, all)
+
+	runs := filterRuns(all, cluster, keyspace)
+
+	var res []runProgress
+	for _, run := range runs {
+		samples := history.Runs[run.ID]
+
+		if baseline, ok := baselineSample(samples, now, window); ok {
+			res = append(res, newRunProgress(run, baseline, now, minRate))
+		} else {
+			res = append(res, runProgress{
+				ID:       run.ID,
+				Cluster:  run.ClusterName,
+				Keyspace: run.KeyspaceName,
+				Total:    run.TotalSegments,
+				To:       run.SegmentsRepaired,
+				Status:   progressCollecting,
+			})
+		}
+
+		samples = append(samples, progressSample{Time: now, Repaired: run.SegmentsRepaired})
+		history.Runs[run.ID] = pruneSamples(samples, now, window)
+	}
+
+	if err := writeProgressHistory(path, history); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func printRunProgress(res []runProgress) {
+	t := table{header: []string{"ID", "CLUSTER", "KEYSPACE", "PROGRESS", "REPAIRED", "RATE", "STATUS"}}
+	for _, p := range res {
+		var (
+			repaired = "-"
+			rate     = "-"
+			c        *color.Color
+		)
+		switch p.Status {
+		case progressStalled:
+			c = color.New(color.FgRed)
+		case progressOK:
+			c = color.New(color.FgGreen)
+		}
+		if p.Status != progressCollecting {
+			repaired = fmt.Sprintf("%d -> %d since %s", p.From, p.To, formatTime(&p.Since))
+			rate = fmt.Sprintf("%.1f/h", p.Rate)
+		}
+
+		t.addRow([]string{
+			p.ID, p.Cluster, p.Keyspace,
+			formatProgress(p.To, p.Total), repaired, rate,
+			p.Status,
+		}, c)
+	}
+	t.print(os.Stdout)
+}
+
+func stalledRepairs(args []string) error {
+	var (
+		fs         = flag.NewFlagSet("stalled-repairs", flag.ContinueOnError)
+		flCluster  = fs.String("cluster", "", "Only check the repairs of this cluster")
+		flKeyspace = fs.String("keyspace", "", "Only check the repairs of this keyspace")
+		flWindow   = fs.Duration("window", time.Hour, "The window over which the progress is measured")
+		flMinRate  = fs.Float64("min-rate", 0, "A repair is stalled if it repairs less segments per hour than this, or none at all")
+		flPersist  = fs.Bool("persist", false, "Don't wait for the window to pass, compare with the samples taken by the previous invocations instead")
+		flFile     = fs.String("file", "", "The file keeping the samples with -persist (default stalled-repairs.yaml next to the configuration file)")
+		flKick     = fs.Bool("kick", false, "Pause and resume the stalled repairs")
+		opts       = newBulkOptions(fs)
+	)
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if *flWindow <= 0 {
+		return errors.Str("please provide a positive window")
+	}
+	if *flFile != "" {
+		*flPersist = true
+	}
+	if *flPersist && *flFile == "" {
+		*flFile = nextToConfig("stalled-repairs.yaml")
+	}
+
+	var res []runProgress
+	if *flPersist {
+		res, err = samplePersisted(*flFile, *flCluster, *flKeyspace, *flWindow, *flMinRate)
+	} else {
+		res, err = sampleLive(*flCluster, *flKeyspace, *flWindow, *flMinRate)
+	}
+	if err != nil {
+		return err
+	}
+
+	if flOutput != OutputText {
+		// With -kick the report of the bulk operation goes to stdout instead.
+		w := os.Stdout
+		if *flKick {
+			w = os.Stderr
+		}
+		if err := writeOutput(w, flOutput, res); err != nil {
+			return err
+		}
+	} else {
+		printRunProgress(res)
+	}
+
+	var stalled []string
+	for _, p := range res {
+		if p.Status == progressStalled {
+			stalled = append(stalled, p.ID)
+		}
+	}
+
+	if !*flKick || len(stalled) == 0 {
+		return nil
+	}
+
+	if !*opts.yes {
+		ok, err := confirm("Pause and resume these %d stalled repairs?", len(stalled))
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	results := runBulk(stalled, *opts.concurrency, func(id string) error {
+		if _, err := client.ChangeRepairRunState(ctx, id, reaper.Paused); err != nil {
+			return err
+		}
+		_, err := client.ChangeRepairRunState(ctx, id, reaper.Running)
+		return err
+	})
+
+	if err := reportBulk(results); err != nil {
+		return err
+	}
+
+	color.Yellow("%d stalled repairs paused and resumed", len(stalled))
+
+	return nil
+}
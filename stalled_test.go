@@ -0,0 +1,156 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/vrischmann/happyreaper/reaper"
+)
+
+var testNow = time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+func samplesAt(ago ...time.Duration) []progressSample {
+	res := make([]progressSample, 0, len(ago))
+	for i, d := range ago {
+		res = append(res, progressSample{Time: testNow.Add(-d), Repaired: i})
+	}
+	return res
+}
+
+func TestBaselineSample(t *testing.T) {
+	testCases := []struct {
+		name    string
+		samples []progressSample
+		found   bool
+		exp     time.Duration
+	}{
+		{"no samples", nil, false, 0},
+		{"all too recent", samplesAt(30*time.Minute, 10*time.Minute), false, 0},
+		{"exactly the window", samplesAt(time.Hour, 10*time.Minute), true, time.Hour},
+		{"most recent old enough", samplesAt(3*time.Hour, 90*time.Minute, 10*time.Minute), true, 90 * time.Minute},
+		{"unordered", samplesAt(90*time.Minute, 3*time.Hour, 2*time.Hour), true, 90 * time.Minute},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, found := baselineSample(tc.samples, testNow, time.Hour)
+			if found != tc.found {
+				t.Fatalf("got found %v, expected %v", found, tc.found)
+			}
+			if found && !res.Time.Equal(testNow.Add(-tc.exp)) {
+				t.Errorf("got sample at %s, expected %s", res.Time, testNow.Add(-tc.exp))
+			}
+		})
+	}
+}
+
+func TestPruneSamples(t *testing.T) {
+	testCases := []struct {
+		name    string
+		samples []progressSample
+		exp     []progressSample
+	}{
+		{"nothing old", samplesAt(30*time.Minute, 10*time.Minute), samplesAt(30*time.Minute, 10*time.Minute)},
+		{
+			"keeps the baseline",
+			samplesAt(3*time.Hour, 2*time.Hour, 30*time.Minute),
+			samplesAt(3*time.Hour, 2*time.Hour, 30*time.Minute)[1:],
+		},
+		{"only old samples", samplesAt(3*time.Hour, 2*time.Hour), samplesAt(3*time.Hour, 2*time.Hour)[1:]},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := pruneSamples(tc.samples, testNow, time.Hour)
+			if !reflect.DeepEqual(res, tc.exp) {
+				t.Errorf("got %v, expected %v", res, tc.exp)
+			}
+		})
+	}
+}
+
+func TestPruneHistory(t *testing.T) {
+	history := progressHistory{Runs: map[string][]progressSample{
+		"a1": samplesAt(time.Hour),
+		"b1": samplesAt(time.Hour),
+		"b2": samplesAt(time.Hour),
+	}}
+	running := []reaper.RepairRun{
+		{ID: "a1", ClusterName: "a"},
+		{ID: "b1", ClusterName: "b"},
+	}
+
+	// The runs of other clusters must survive an invocation filtered on cluster a.
+	pruneHistory(history, running)
+
+	var ids []string
+	for id := range history.Runs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	if exp := []string{"a1", "b1"}; !reflect.DeepEqual(ids, exp) {
+		t.Errorf("got runs %v, expected %v", ids, exp)
+	}
+}
+
+func TestFilterRuns(t *testing.T) {
+	runs := []reaper.RepairRun{
+		{ID: "1", ClusterName: "a", KeyspaceName: "ks1"},
+		{ID: "2", ClusterName: "a", KeyspaceName: "ks2"},
+		{ID: "3", ClusterName: "b", KeyspaceName: "ks1"},
+	}
+
+	testCases := []struct {
+		cluster  string
+		keyspace string
+		exp      []string
+	}{
+		{"", "", []string{"1", "2", "3"}},
+		{"a", "", []string{"1", "2"}},
+		{"", "ks1", []string{"1", "3"}},
+		{"b", "ks2", nil},
+	}
+
+	for _, tc := range testCases {
+		var ids []string
+		for _, run := range filterRuns(runs, tc.cluster, tc.keyspace) {
+			ids = append(ids, run.ID)
+		}
+		if !reflect.DeepEqual(ids, tc.exp) {
+			t.Errorf("filterRuns(%q, %q): got %v, expected %v", tc.cluster, tc.keyspace, ids, tc.exp)
+		}
+	}
+}
+
+func TestNewRunProgress(t *testing.T) {
+	testCases := []struct {
+		name     string
+		repaired int
+		minRate  float64
+		rate     float64
+		status   string
+	}{
+		{"progressing", 14, 0, 2, progressOK},
+		{"no progress", 10, 0, 0, progressStalled},
+		{"below the minimum rate", 14, 3, 2, progressStalled},
+		{"above the minimum rate", 14, 1, 2, progressOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := reaper.RepairRun{ID: "1", TotalSegments: 100, SegmentsRepaired: tc.repaired}
+			baseline := progressSample{Time: testNow.Add(-2 * time.Hour), Repaired: 10}
+
+			res := newRunProgress(run, baseline, testNow, tc.minRate)
+			if res.Rate != tc.rate {
+				t.Errorf("got rate %v, expected %v", res.Rate, tc.rate)
+			}
+			if res.Status != tc.status {
+				t.Errorf("got status %s, expected %s", res.Status, tc.status)
+			}
+		})
+	}
+}
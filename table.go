@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/vrischmann/happyreaper/errors"
+	"github.com/vrischmann/happyreaper/reaper"
+)
+
+var (
+	defaultRunColumns      = []string{"id", "cluster_name", "keyspace_name", "state", "progress", "intensity", "start_time", "duration"}
+	defaultScheduleColumns = []string{"id", "cluster_name", "keyspace_name", "state", "intensity", "repair_parallelism", "scheduled_days_between", "next_activation"}
+	defaultSegmentColumns  = []string{"id", "start_token", "end_token", "state", "coordinator_host", "fail_count", "start_time", "end_time"}
+)
+
+// runTableFields are the fields only available in the table view.
+var runTableFields = []runField{
+	{"progress", func(r reaper.RepairRun) string { return formatProgress(r.SegmentsRepaired, r.TotalSegments) }},
+}
+
+func formatProgress(repaired, total int) string {
+	if total <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(repaired)*100/float64(total))
+}
+
+func findRunField(name string) (runField, bool) {
+	for _, f := range runFields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range runTableFields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return runField{}, false
+}
+
+func findScheduleField(name string) (scheduleField, bool) {
+	for _, f := range scheduleFields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return scheduleField{}, false
+}
+
+func findSegmentField(name string) (segmentField, bool) {
+	for _, f := range segmentFields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return segmentField{}, false
+}
+
+func runStateColor(state reaper.RunState) *color.Color {
+	switch state {
+	case reaper.Running:
+		return color.New(color.FgGreen)
+	case reaper.Done:
+		return color.New(color.FgBlue)
+	case reaper.Paused:
+		return color.New(color.FgYellow)
+	case reaper.Error, reaper.Aborted:
+		return color.New(color.FgRed)
+	case reaper.Deleted:
+		return color.New(color.FgHiBlack)
+	default:
+		return nil
+	}
+}
+
+func scheduleStateColor(state reaper.ScheduleState) *color.Color {
+	switch state {
+	case reaper.SActive:
+		return color.New(color.FgGreen)
+	case reaper.SPaused:
+		return color.New(color.FgYellow)
+	case reaper.SDeleted:
+		return color.New(color.FgHiBlack)
+	default:
+		return nil
+	}
+}
+
+// segmentColor highlights the segments which failed at least once.
+func segmentColor(seg reaper.Segment) *color.Color {
+	switch {
+	case seg.FailCount > 0:
+		return color.New(color.FgRed)
+	case seg.State == reaper.SegRunning || seg.State == reaper.SegStarted:
+		return color.New(color.FgGreen)
+	case seg.State == reaper.SegDone:
+		return color.New(color.FgBlue)
+	default:
+		return nil
+	}
+}
+
+// table accumulates rows and prints them with aligned columns.
+//
+// text/tabwriter is not used because it counts the color escape sequences in the cell width.
+type table struct {
+	header []string
+	rows   [][]string
+	colors []*color.Color
+}
+
+func (t *table) addRow(row []string, c *color.Color) {
+	t.rows = append(t.rows, row)
+	t.colors = append(t.colors, c)
+}
+
+func (t *table) print(w io.Writer) {
+	widths := make([]int, len(t.header))
+	for i, h := range t.header {
+		widths[i] = len(h)
+	}
+	for _, row := range t.rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+
+	format := func(row []string) string {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = fmt.Sprintf("%-*s", widths[i], cell)
+		}
+		return strings.TrimRight(strings.Join(cells, "  "), " ")
+	}
+
+	fmt.Fprintln(w, format(t.header))
+	for i, row := range t.rows {
+		line := format(row)
+		if c := t.colors[i]; c != nil {
+			line = c.Sprint(line)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+func columnHeader(name string) string {
+	return strings.ToUpper(strings.Replace(name, "_", " ", -1))
+}
+
+func printRunsTable(w io.Writer, runs []reaper.RepairRun, columns []string, colored bool) error {
+	if len(columns) == 0 {
+		columns = defaultRunColumns
+	}
+
+	fields := make([]runField, 0, len(columns))
+	t := table{}
+	for _, name := range columns {
+		f, ok := findRunField(name)
+		if !ok {
+			return errors.Errorf("invalid column %q", name)
+		}
+		fields = append(fields, f)
+		t.header = append(t.header, columnHeader(name))
+	}
+
+	for _, run := range runs {
+		row := make([]string, 0, len(fields))
+		for _, f := range fields {
+			row = append(row, f.get(run))
+		}
+
+		var c *color.Color
+		if colored {
+			c = runStateColor(run.State)
+		}
+		t.addRow(row, c)
+	}
+
+	t.print(w)
+
+	return nil
+}
+
+func printSchedulesTable(w io.Writer, schedules []reaper.RepairSchedule, columns []string, colored bool) error {
+	if len(columns) == 0 {
+		columns = defaultScheduleColumns
+	}
+
+	fields := make([]scheduleField, 0, len(columns))
+	t := table{}
+	for _, name := range columns {
+		f, ok := findScheduleField(name)
+		if !ok {
+			return errors.Errorf("invalid column %q", name)
+		}
+		fields = append(fields, f)
+		t.header = append(t.header, columnHeader(name))
+	}
+
+	for _, sched := range schedules {
+		row := make([]string, 0, len(fields))
+		for _, f := range fields {
+			row = append(row, f.get(sched))
+		}
+
+		var c *color.Color
+		if colored {
+			c = scheduleStateColor(sched.State)
+		}
+		t.addRow(row, c)
+	}
+
+	t.print(w)
+
+	return nil
+}
+
+func printSegmentsTable(w io.Writer, segments []reaper.Segment, columns []string, colored bool) error {
+	if len(columns) == 0 {
+		columns = defaultSegmentColumns
+	}
+
+	fields := make([]segmentField, 0, len(columns))
+	t := table{}
+	for _, name := range columns {
+		f, ok := findSegmentField(name)
+		if !ok {
+			return errors.Errorf("invalid column %q", name)
+		}
+		fields = append(fields, f)
+		t.header = append(t.header, columnHeader(name))
+	}
+
+	for _, seg := range segments {
+		row := make([]string, 0, len(fields))
+		for _, f := range fields {
+			row = append(row, f.get(seg))
+		}
+
+		var c *color.Color
+		if colored {
+			c = segmentColor(seg)
+		}
+		t.addRow(row, c)
+	}
+
+	t.print(w)
+
+	return nil
+}
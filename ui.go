@@ -0,0 +1,416 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/vrischmann/happyreaper/reaper"
+)
+
+// dashboard is the state of the ui command.
+//
+// The widgets and the runs and schedules shown are only touched from the tview event loop,
+// the data is fetched by a separate goroutine which hands it over with QueueUpdateDraw.
+type dashboard struct {
+	app       *tview.Application
+	pages     *tview.Pages
+	clusters  *tview.List
+	runs      *tview.Table
+	schedules *tview.Table
+	details   *tview.TextView
+	status    *tview.TextView
+
+	interval time.Duration
+	refresh  chan struct{}
+
+	mu      sync.Mutex
+	cluster string
+
+	runsData      []reaper.RepairRun
+	schedulesData []reaper.RepairSchedule
+}
+
+func runUI(args []string) error {
+	var (
+		fs         = flag.NewFlagSet("ui", flag.ContinueOnError)
+		flInterval = fs.Duration("interval", 5*time.Second, "The refresh interval")
+	)
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	d := newDashboard(*flInterval)
+
+	go d.fetchLoop()
+
+	return d.app.Run()
+}
+
+func newDashboard(interval time.Duration) *dashboard {
+	d := &dashboard{
+		app:       tview.NewApplication(),
+		pages:     tview.NewPages(),
+		clusters:  tview.NewList().ShowSecondaryText(false),
+		runs:      tview.NewTable().SetSelectable(true, false).SetFixed(1, 0),
+		schedules: tview.NewTable().SetSelectable(true, false).SetFixed(1, 0),
+		details:   tview.NewTextView(),
+		status:    tview.NewTextView(),
+		interval:  interval,
+		refresh:   make(chan struct{}, 1),
+	}
+
+	d.clusters.SetBorder(true).SetTitle(" Clusters ")
+	d.runs.SetBorder(true).SetTitle(" Repair runs ")
+	d.schedules.SetBorder(true).SetTitle(" Schedules ")
+	d.details.SetBorder(true).SetTitle(" Details ")
+	d.status.SetText("tab: switch pane  p: pause  r: resume  d: delete  q: quit")
+
+	d.clusters.SetChangedFunc(d.selectCluster)
+	d.runs.SetSelectionChangedFunc(func(row, _ int) {
+		if run, ok := d.selectedRun(); ok {
+			d.details.SetText(fmt.Sprintf("%+v", run)).ScrollToBeginning()
+		}
+	})
+	d.schedules.SetSelectionChangedFunc(func(row, _ int) {
+		if sched, ok := d.selectedSchedule(); ok {
+			d.details.SetText(fmt.Sprintf("%+v", sched)).ScrollToBeginning()
+		}
+	})
+
+	d.runs.SetInputCapture(d.runKeys)
+	d.schedules.SetInputCapture(d.scheduleKeys)
+	d.app.SetInputCapture(d.globalKeys)
+
+	lists := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(d.runs, 0, 2, false).
+		AddItem(d.schedules, 0, 1, false)
+	panes := tview.NewFlex().
+		AddItem(d.clusters, 25, 0, true).
+		AddItem(lists, 0, 3, false).
+		AddItem(d.details, 0, 1, false)
+	root := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(panes, 0, 1, true).
+		AddItem(d.status, 1, 0, false)
+
+	d.pages.AddPage("main", root, true, true)
+	d.app.SetRoot(d.pages, true)
+
+	return d
+}
+
+func (d *dashboard) selectCluster(_ int, name string, _ string, _ rune) {
+	d.mu.Lock()
+	d.cluster = name
+	d.mu.Unlock()
+
+	d.refreshNow()
+}
+
+func (d *dashboard) refreshNow() {
+	select {
+	case d.refresh <- struct{}{}:
+	default:
+	}
+}
+
+func (d *dashboard) setStatus(format string, args ...interface{}) {
+	d.app.QueueUpdateDraw(func() {
+		d.status.SetText(fmt.Sprintf(format, args...))
+	})
+}
+
+// fetchLoop fetches the clusters and the runs and schedules of the selected cluster
+// every interval or when a refresh is requested, until ctx is cancelled.
+func (d *dashboard) fetchLoop() {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		d.fetch()
+
+		select {
+		case <-ticker.C:
+		case <-d.refresh:
+		case <-ctx.Done():
+			d.app.Stop()
+			return
+		}
+	}
+}
+
+func (d *dashboard) fetch() {
+	names, err := client.ListClusters(ctx)
+	if err != nil {
+		d.setStatus("unable to list the clusters: %s", describeError(err))
+		return
+	}
+
+	d.mu.Lock()
+	name := d.cluster
+	d.mu.Unlock()
+
+	if name == "" && len(names) > 0 {
+		name = names[0]
+	}
+
+	var cl reaper.Cluster
+	if name != "" {
+		cl, err = client.GetCluster(ctx, name)
+		if err != nil {
+			d.setStatus("unable to get cluster %s: %s", name, describeError(err))
+			return
+		}
+	}
+
+	sortSchedules(cl.RepairSchedules, ScheduleSortByNextActivation, false)
+
+	d.app.QueueUpdateDraw(func() {
+		d.renderClusters(names)
+		d.renderRuns(cl.RepairRuns)
+		d.renderSchedules(cl.RepairSchedules)
+	})
+}
+
+func (d *dashboard) renderClusters(names []string) {
+	current := d.clusters.GetCurrentItem()
+
+	// Avoid triggering the changed func, and thus a refresh, on every render.
+	d.clusters.SetChangedFunc(nil)
+	d.clusters.Clear()
+	for _, name := range names {
+		d.clusters.AddItem(name, "", 0, nil)
+	}
+	if current < len(names) {
+		d.clusters.SetCurrentItem(current)
+	}
+	d.clusters.SetChangedFunc(d.selectCluster)
+}
+
+func runStateTcellColor(state reaper.RunState) tcell.Color {
+	switch state {
+	case reaper.Running:
+		return tcell.ColorGreen
+	case reaper.Done:
+		return tcell.ColorBlue
+	case reaper.Paused:
+		return tcell.ColorYellow
+	case reaper.Error, reaper.Aborted:
+		return tcell.ColorRed
+	case reaper.Deleted:
+		return tcell.ColorGray
+	default:
+		return tcell.ColorWhite
+	}
+}
+
+func scheduleStateTcellColor(state reaper.ScheduleState) tcell.Color {
+	switch state {
+	case reaper.SActive:
+		return tcell.ColorGreen
+	case reaper.SPaused:
+		return tcell.ColorYellow
+	case reaper.SDeleted:
+		return tcell.ColorGray
+	default:
+		return tcell.ColorWhite
+	}
+}
+
+func setRow(t *tview.Table, row int, c tcell.Color, cells ...string) {
+	for i, text := range cells {
+		cell := tview.NewTableCell(text).SetTextColor(c)
+		if row == 0 {
+			cell.SetSelectable(false).SetAttributes(tcell.AttrBold)
+		}
+		t.SetCell(row, i, cell)
+	}
+}
+
+func (d *dashboard) renderRuns(runs []reaper.RepairRun) {
+	d.runsData = runs
+
+	row, _ := d.runs.GetSelection()
+
+	d.runs.Clear()
+	setRow(d.runs, 0, tcell.ColorWhite, "ID", "KEYSPACE", "STATE", "PROGRESS", "")
+	for i, run := range runs {
+		setRow(d.runs, i+1, runStateTcellColor(run.State),
+			run.ID, run.KeyspaceName, run.State.String(),
+			tview.Escape("["+progressBar(run, 20)+"]"), formatProgress(run.SegmentsRepaired, run.TotalSegments),
+		)
+	}
+
+	d.runs.Select(clampRow(row, len(runs)), 0)
+}
+
+func (d *dashboard) renderSchedules(schedules []reaper.RepairSchedule) {
+	d.schedulesData = schedules
+
+	row, _ := d.schedules.GetSelection()
+
+	d.schedules.Clear()
+	setRow(d.schedules, 0, tcell.ColorWhite, "ID", "KEYSPACE", "STATE", "NEXT ACTIVATION")
+	for i, sched := range schedules {
+		setRow(d.schedules, i+1, scheduleStateTcellColor(sched.State),
+			sched.ID, sched.KeyspaceName, sched.State.String(), formatTime(sched.NextActivation),
+		)
+	}
+
+	d.schedules.Select(clampRow(row, len(schedules)), 0)
+}
+
+// clampRow keeps the selection on a data row, the first row being the header.
+func clampRow(row, n int) int {
+	switch {
+	case row > n:
+		return n
+	case row < 1:
+		return 1
+	default:
+		return row
+	}
+}
+
+func (d *dashboard) selectedRun() (reaper.RepairRun, bool) {
+	row, _ := d.runs.GetSelection()
+	if row < 1 || row > len(d.runsData) {
+		return reaper.RepairRun{}, false
+	}
+	return d.runsData[row-1], true
+}
+
+func (d *dashboard) selectedSchedule() (reaper.RepairSchedule, bool) {
+	row, _ := d.schedules.GetSelection()
+	if row < 1 || row > len(d.schedulesData) {
+		return reaper.RepairSchedule{}, false
+	}
+	return d.schedulesData[row-1], true
+}
+
+func (d *dashboard) globalKeys(ev *tcell.EventKey) *tcell.EventKey {
+	// Let the confirmation dialog handle its own keys.
+	if name, _ := d.pages.GetFrontPage(); name != "main" {
+		return ev
+	}
+
+	switch {
+	case ev.Key() == tcell.KeyTab:
+		switch d.app.GetFocus() {
+		case d.clusters:
+			d.app.SetFocus(d.runs)
+		case d.runs:
+			d.app.SetFocus(d.schedules)
+		default:
+			d.app.SetFocus(d.clusters)
+		}
+		return nil
+
+	case ev.Rune() == 'q':
+		d.app.Stop()
+		return nil
+	}
+
+	return ev
+}
+
+// do runs fn in the background and reports its outcome in the status bar.
+func (d *dashboard) do(description string, fn func() error) {
+	d.status.SetText(description + "...")
+
+	go func() {
+		if err := fn(); err != nil {
+			d.setStatus("%s failed: %s", description, describeError(err))
+		} else {
+			d.setStatus("%s done", description)
+		}
+		d.refreshNow()
+	}()
+}
+
+// confirm shows a dialog and calls fn if the user accepts.
+func (d *dashboard) confirm(text string, fn func()) {
+	modal := tview.NewModal().
+		SetText(text).
+		AddButtons([]string{"Cancel", "Delete"}).
+		SetDoneFunc(func(_ int, label string) {
+			d.pages.RemovePage("confirm")
+			if label == "Delete" {
+				fn()
+			}
+		})
+
+	d.pages.AddPage("confirm", modal, false, true)
+}
+
+func (d *dashboard) runKeys(ev *tcell.EventKey) *tcell.EventKey {
+	run, ok := d.selectedRun()
+	if !ok {
+		return ev
+	}
+
+	changeState := func(state reaper.RunState) {
+		d.do(fmt.Sprintf("changing the state of repair %s to %s", run.ID, state), func() error {
+			_, err := client.ChangeRepairRunState(ctx, run.ID, state)
+			return err
+		})
+	}
+
+	switch ev.Rune() {
+	case 'p':
+		changeState(reaper.Paused)
+	case 'r':
+		changeState(reaper.Running)
+	case 'd':
+		d.confirm(fmt.Sprintf("Delete repair %s?", run.ID), func() {
+			d.do(fmt.Sprintf("deleting repair %s", run.ID), func() error {
+				_, err := client.DeleteRepairRun(ctx, run.ID, run.Owner)
+				return err
+			})
+		})
+	default:
+		return ev
+	}
+
+	return nil
+}
+
+func (d *dashboard) scheduleKeys(ev *tcell.EventKey) *tcell.EventKey {
+	sched, ok := d.selectedSchedule()
+	if !ok {
+		return ev
+	}
+
+	changeState := func(state reaper.ScheduleState) {
+		d.do(fmt.Sprintf("changing the state of schedule %s to %s", sched.ID, state), func() error {
+			_, err := client.ChangeScheduleState(ctx, sched.ID, state)
+			return err
+		})
+	}
+
+	switch ev.Rune() {
+	case 'p':
+		changeState(reaper.SPaused)
+	case 'r':
+		changeState(reaper.SActive)
+	case 'd':
+		d.confirm(fmt.Sprintf("Delete schedule %s?", sched.ID), func() {
+			d.do(fmt.Sprintf("deleting schedule %s", sched.ID), func() error {
+				_, err := client.DeleteSchedule(ctx, sched.ID, sched.Owner)
+				return err
+			})
+		})
+	default:
+		return ev
+	}
+
+	return nil
+}